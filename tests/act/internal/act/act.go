@@ -2,11 +2,12 @@ package act
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
+	"maps"
 	"net"
 	"os"
 	"os/exec"
@@ -16,29 +17,24 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
 )
 
 var (
-	logUUIDRegex           = regexp.MustCompile(`-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
-	selfHostedRunnerLabels = [...]string{
-		"ubuntu-x64-small",
-		"ubuntu-x64",
-		"ubuntu-x64-large",
-		"ubuntu-x64-xlarge",
-		"ubuntu-x64-2xlarge",
-		"ubuntu-arm64-small",
-		"ubuntu-arm64",
-		"ubuntu-arm64-large",
-		"ubuntu-arm64-xlarge",
-		"ubuntu-arm64-2xlarge",
-	}
+	logUUIDRegex   = regexp.MustCompile(`-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
 	globalLogMutex sync.Mutex
 )
 
-const nektosActRunnerImage = "ghcr.io/catthehacker/ubuntu:act-latest"
+// DefaultRunTimeout is the default maximum duration of a single act run.
+// It can be changed per Runner via WithTimeout.
+const DefaultRunTimeout = 30 * time.Minute
+
+// killGracePeriod is how long RunContext waits for the act output stream to be closed
+// after killing act, before giving up on reading the remaining output.
+const killGracePeriod = 10 * time.Second
 
 // Runner is a test runner that can execute GitHub Actions workflows using act.
 type Runner struct {
@@ -56,6 +52,9 @@ type Runner struct {
 	// If empty, a new temporary directory is created for each runner.
 	actionsCachePath string
 
+	// actionsCacheCheckedOut is true if the per-runner actions cache has been checked out from the template cache.
+	actionsCacheCheckedOut bool
+
 	// ArtifactsStorage is the storage for artifacts uploaded during the workflow run.
 	ArtifactsStorage ArtifactsStorage
 
@@ -69,7 +68,11 @@ type Runner struct {
 	// It records inputs from the mocked Argo Workflow trigger step.
 	Argo *HTTPSpy
 
+	// tokenProvider provides the GitHub token. See WithTokenProvider.
+	tokenProvider TokenProvider
+
 	// gitHubToken is the token used to authenticate with GitHub.
+	// If empty, act runs in offline mode. See AnonymousTokenProvider.
 	gitHubToken string
 
 	// ConcurrentJobs defines the number of jobs to run concurrently via act.
@@ -79,11 +82,56 @@ type Runner struct {
 	// Verbose enables logging of JSON output from act back to stdout.
 	Verbose bool
 
+	// RetryPolicy defines how runs failing because of the infrastructure are retried. See WithRetryPolicy.
+	RetryPolicy RetryPolicy
+
+	// KeepOnFailure keeps the state created by the Runner when the test fails, for debugging.
+	// By default, all the state is removed when the test ends. See WithKeepOnFailure.
+	KeepOnFailure bool
+
+	// secrets, variables and env are injected into act via --secret-file, --var-file and --env-file.
+	// See WithSecret, WithVariable and WithEnv.
+	secrets   map[string]string
+	variables map[string]string
+	env       map[string]string
+
+	// secretsFiles are dotenv files with additional secrets to inject into act. See WithSecretsFile.
+	secretsFiles []string
+
+	// maskedValues contains additional values to mask in the act output. See WithMaskedValues.
+	maskedValues []string
+
+	// jobID is the ID of the only job to run, in the workflow or its children. See WithJob.
+	jobID string
+
+	// matrix contains the key:value matrix filters passed to act. See WithMatrix.
+	matrix []string
+
+	// runnerImages maps the runner labels to the images used by act. See WithRunnerImage.
+	runnerImages map[string]RunnerImage
+
 	// ContainerArchitecture is the architecture to use for act containers.
 	// By default, act uses the architecture of the host machine.
 	// This can be useful to force a specific platform when running on ARM Macs.
 	ContainerArchitecture string
 
+	// Timeout is the maximum duration of a single act run.
+	// When it expires, the act process tree and its job containers are killed.
+	// By default, this is DefaultRunTimeout. Zero or a negative value disables the timeout.
+	Timeout time.Duration
+
+	// recordingPath is the path of the fixture file where the act output is recorded.
+	// If empty, the act output is not recorded. See WithRecording.
+	recordingPath string
+
+	// scheduler admits the act runs based on a process-wide resource budget.
+	// By default, this is DefaultScheduler, but can be overridden with WithScheduler.
+	scheduler *Scheduler
+
+	// executor is the Executor used to run act.
+	// By default, this is an ActCLIExecutor, but can be overridden with WithExecutor.
+	executor Executor
+
 	// inGitHubActions indicates whether the runner is executing in a GitHub Actions environment.
 	inGitHubActions bool
 }
@@ -118,6 +166,22 @@ func WithLinuxAMD64ContainerArchitecture() RunnerOption {
 	return WithContainerArchitecture("linux/amd64")
 }
 
+// WithTimeout sets the maximum duration of a single act run.
+// Zero or a negative value disables the timeout.
+func WithTimeout(timeout time.Duration) RunnerOption {
+	return func(r *Runner) {
+		r.Timeout = timeout
+	}
+}
+
+// WithExecutor sets the Executor used to run act.
+// By default, the Runner uses an ActCLIExecutor, which runs the act CLI.
+func WithExecutor(executor Executor) RunnerOption {
+	return func(r *Runner) {
+		r.executor = executor
+	}
+}
+
 // WithActionsCachePath sets the actions cache path for the runner (absolute path).
 func WithActionsCachePath(cachePath string) RunnerOption {
 	return func(r *Runner) {
@@ -135,41 +199,49 @@ func WithName(name string) RunnerOption {
 
 // NewRunner creates a new Runner instance.
 func NewRunner(t *testing.T, opts ...RunnerOption) (*Runner, error) {
-	// Get GitHub token from environment (GHA) or gh CLI (local)
-	ghToken, ok := os.LookupEnv("GITHUB_TOKEN")
-	if !ok || ghToken == "" {
-		cmd := exec.Command("gh", "auth", "token")
-		output, err := cmd.Output()
-		if err != nil {
-			return nil, fmt.Errorf("exec 'gh auth token': %w", err)
-		}
-		ghToken = strings.TrimSpace(string(output))
-	}
 	r := &Runner{
 		t:               t,
 		name:            t.Name(),
 		uuid:            uuid.New(),
-		gitHubToken:     ghToken,
+		tokenProvider:   DefaultTokenProvider,
 		inGitHubActions: os.Getenv("GITHUB_ACTIONS") == "true",
+		Timeout:         DefaultRunTimeout,
+		scheduler:       DefaultScheduler,
+		runnerImages:    maps.Clone(DefaultRunnerImages),
+		secrets:         map[string]string{},
+		variables:       map[string]string{},
+		env:             map[string]string{},
+		recordingPath:   recordingPathFromEnv(t),
 		GCOM:            newGCOM(t),
 		Argo: NewHTTPSpy(t, map[string]string{
 			"uri": "https://mock-argo-workflows.example.com/workflows/grafana-plugins-cd/mock-workflow-id",
 		}),
 	}
-	if err := r.checkExecutables(); err != nil {
-		return nil, err
-	}
 	r.ArtifactsStorage = newArtifactsStorage(r)
+	r.removeOnCleanup(r.ArtifactsStorage.basePath)
 	var err error
 	r.GCS, err = newGCS(r)
 	if err != nil {
 		return nil, fmt.Errorf("new gcs: %w", err)
 	}
+	r.removeOnCleanup(r.GCS.basePath)
 
 	// Apply options
 	for _, opt := range opts {
 		opt(r)
 	}
+	// Get the GitHub token after applying the options, so the token provider can be overridden.
+	r.gitHubToken, err = r.tokenProvider.Token()
+	if err != nil {
+		return nil, fmt.Errorf("get github token: %w", err)
+	}
+	// Default to running the act CLI if no executor is set.
+	if r.executor == nil {
+		r.executor, err = NewActCLIExecutor()
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Default to a new temporary directory for the actions cache if not set.
 	if r.actionsCachePath == "" {
 		WithActionsCachePath(filepath.Join(actionsCachePathBase, r.uuid.String()))(r)
@@ -180,12 +252,28 @@ func NewRunner(t *testing.T, opts ...RunnerOption) (*Runner, error) {
 // args returns the CLI arguments to pass to act for the given workflow and event payload files.
 // It also returns the port number holding the artifact server port open.
 // The caller must call markPortAsFree with the returned port value to mark the port as free again after running act.
-func (r *Runner) args(eventKind EventKind, actor string, workflowFile string, payloadFile string) ([]string, int, error) {
+// On error, the port is already marked as free.
+func (r *Runner) args(eventKind EventKind, actor string, workflowFile string, payloadFile string) (_ []string, _ int, err error) {
 	// Get a unique free port for the act artifact server, so multiple act instances can run in parallel
 	artifactServerPort, err := getFreePort()
 	if err != nil {
 		return nil, 0, fmt.Errorf("get free port for artifact server: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			markPortAsFree(artifactServerPort)
+		}
+	}()
+	// Always pass our own files, so act doesn't pick up .secrets, .vars or .env from the working directory
+	secretsFile, varsFile, envFile, err := r.writeInputFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	containerOptions, err := r.containerOptions()
+	if err != nil {
+		return nil, 0, err
+	}
 
 	args := []string{
 		// Positional args: event kind
@@ -200,19 +288,30 @@ func (r *Runner) args(eventKind EventKind, actor string, workflowFile string, pa
 		fmt.Sprintf("--artifact-server-port=%d", artifactServerPort),
 		"--artifact-server-path=/tmp/act-artifacts/" + r.uuid.String() + "/",
 
-		// Required for cloning private repos
-		"--secret", "GITHUB_TOKEN=" + r.gitHubToken,
+		"--secret-file", secretsFile,
+		"--var-file", varsFile,
+		"--env-file", envFile,
 
 		// Additional Docker flags
-		"--container-options", r.containerOptions(),
+		"--container-options", containerOptions,
+	}
+	if r.gitHubToken != "" {
+		// Required for cloning private repos
+		args = append(args, "--secret", "GITHUB_TOKEN="+r.gitHubToken)
+	} else {
+		// Anonymous mode: resolve actions from the (pre-warmed) actions cache and use local Docker images only
+		args = append(args, "--action-offline-mode", "--pull=false")
 	}
 	if r.actionsCachePath != "" {
-		// Create and use per-runner cache.
+		// Create and use per-runner cache, checked out from the template cache once per runner.
 		// Do not pre-populate the cache if we are using the shared cache (cache warmup).
-		if r.actionsCachePath != TemplateActionsCachePath {
-			if err := copyDir(TemplateActionsCachePath, r.actionsCachePath); err != nil {
-				return nil, 0, fmt.Errorf("copy action cache: %w", err)
+		if r.actionsCachePath != TemplateActionsCachePath && !r.actionsCacheCheckedOut {
+			release, err := defaultActionsCacheStore.checkout(r.actionsCachePath)
+			if err != nil {
+				return nil, 0, fmt.Errorf("checkout action cache: %w", err)
 			}
+			r.actionsCacheCheckedOut = true
+			r.onCleanup("actions cache "+r.actionsCachePath, release)
 		}
 		args = append(args, "--action-cache-path", r.actionsCachePath)
 	} else {
@@ -235,10 +334,6 @@ func (r *Runner) args(eventKind EventKind, actor string, workflowFile string, pa
 	if r.ContainerArchitecture != "" {
 		args = append(args, "--container-architecture", r.ContainerArchitecture)
 	}
-	// Map all self-hosted runners otherwise they don't run in act.
-	for _, label := range selfHostedRunnerLabels {
-		args = append(args, "-P", label+"="+nektosActRunnerImage)
-	}
 	return args, artifactServerPort, nil
 }
 
@@ -300,9 +395,57 @@ func (r *Runner) localRepositoryArgs() (args []string, err error) {
 }
 
 // Run runs the given workflow with the given event payload using act.
-func (r *Runner) Run(workflow workflow.Workflow, event Event) (runResult *RunResult, err error) {
+// It is a shortcut for RunContext with a background context.
+func (r *Runner) Run(workflow workflow.Workflow, event Event) (*RunResult, error) {
+	return r.RunContext(context.Background(), workflow, event)
+}
+
+// RunContext runs the given workflow with the given event payload using act.
+// The run is bounded by the Runner's Timeout (if any) and by the given context.
+// If the context is cancelled or the timeout expires before act exits, the whole act process tree
+// and the job containers of the workflow are killed. In that case, RunContext returns a partial RunResult
+// (with Cancelled set to true and RunningJobs listing the jobs that were still running)
+// together with an error wrapping the context error.
+// Before starting act, the run waits to be admitted by the Runner's Scheduler (see WithScheduler).
+// If act fails because of the infrastructure, the failure is reported in RunResult.InfraFailure.
+// If the Runner has a RetryPolicy (see WithRetryPolicy), the run is retried, and RunContext returns the RunResult
+// together with the *InfraError if all the attempts failed because of the infrastructure.
+func (r *Runner) RunContext(ctx context.Context, workflow workflow.Workflow, event Event) (*RunResult, error) {
+	return r.runWithRetries(ctx, workflow, event)
+}
+
+// runOnce runs the given workflow with the given event payload using act, without retries. See RunContext.
+func (r *Runner) runOnce(ctx context.Context, workflow workflow.Workflow, event Event) (runResult *RunResult, err error) {
+	// Map the runner labels to images, so jobs are not silently skipped by act, and resolve the selected job.
+	// This is done before waiting for our turn, to fail fast.
+	platformArgs, err := r.platformArgs(workflow)
+	if err != nil {
+		return nil, err
+	}
+	jobSelection, err := r.resolveJobSelection(workflow)
+	if err != nil {
+		return nil, err
+	}
+	// act's artifact server doesn't store the retention of the artifacts, so it's resolved from the workflow
+	// and recorded for the run once its ID is known
+	artifactRetentions := workflowArtifactRetentions(workflow)
+
+	// Wait for our turn before starting the timeout, so the queue time doesn't count toward it
+	release, queueTime, err := r.schedule(ctx, workflow)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
 	result := newRunResult()
 	runResult = &result
+	runResult.QueueTime = queueTime
 
 	// Create temp workflow file inside .github/workflows or act won't
 	// map the repo to the workflow correctly.
@@ -310,11 +453,18 @@ func (r *Runner) Run(workflow workflow.Workflow, event Event) (runResult *RunRes
 	if err != nil {
 		return nil, fmt.Errorf("create temp workflow file: %w", err)
 	}
-	// TODO: enable again and also remove child workflows
-	// defer os.Remove(workflowFile)
+	// Keep the workflow files until the end of the test, for debugging and for running the same workflow again
+	r.onCleanup("workflow files of "+workflowFile, func() error {
+		return RemoveTempWorkflowFiles(workflow)
+	})
+	if jobSelection != nil {
+		if err := jobSelection.writeChildWorkflowFiles(); err != nil {
+			return nil, fmt.Errorf("select job: %w", err)
+		}
+	}
 
 	// Create temp event payload file to simulate a GitHub event
-	payloadFile, err := CreateTempEventFile(event)
+	payloadFile, err := CreateTempEventFile(withDefaultDispatchedWorkflow(event, workflowFile))
 	if err != nil {
 		return nil, fmt.Errorf("create temp event file: %w", err)
 	}
@@ -329,80 +479,104 @@ func (r *Runner) Run(workflow workflow.Workflow, event Event) (runResult *RunRes
 		return nil, fmt.Errorf("get act args: %w", err)
 	}
 	defer markPortAsFree(artifactServerPort)
-
-	// TODO: escape args to avoid shell injection
-	actCmd := "act " + strings.Join(args, " ")
-
-	// Use a shell otherwise git will not be able to clone anything,
-	// not even publis repositories like actions/checkout for some reason.
-	cmd := exec.Command("sh", "-c", actCmd)
-	cmd.Env = os.Environ()
-
-	// Get stdout and stderr pipes to parse act output
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("get act stdout pipe: %w", err)
+	args = append(args, platformArgs...)
+	if jobSelection != nil {
+		args = append(args, jobSelection.args()...)
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("get act stderr pipe: %w", err)
+	for _, matrix := range r.matrix {
+		args = append(args, "--matrix", matrix)
 	}
 
-	// Merge stdout and stderr into a single reader so both are grouped in GHA logs
-	mergedR, mergedW := io.Pipe()
-	go func() {
-		var wg sync.WaitGroup
-		wg.Add(2)
-		go func() {
-			defer wg.Done()
-			_, _ = io.Copy(mergedW, stdout)
-		}()
-		go func() {
-			defer wg.Done()
-			_, _ = io.Copy(mergedW, stderr)
-		}()
-		wg.Wait()
-		_ = mergedW.Close()
-	}()
-
-	// Run act in the background
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("start act: %w", err)
+	// Record the output, if requested, so it can be replayed later.
+	// The recording is created before starting act, so act is never left running if it can't be created.
+	var recording *os.File
+	var recordingWriter io.Writer
+	if r.recordingPath != "" {
+		recording, err = createRecording(r.recordingPath)
+		if err != nil {
+			return nil, fmt.Errorf("create recording: %w", err)
+		}
+		recordingWriter = recording
 	}
 
-	// Process json logs in merged stdout/stderr stream
-	// This must complete BEFORE cmd.Wait() is called, because cmd.Wait()
-	// closes the stdout pipe. If the goroutine is still reading when the
-	// pipe is closed, it will get a "file already closed" error.
-	errs := make(chan error, 1)
-	go func() {
-		if err := r.processStream(mergedR, runResult); err != nil {
-			errs <- fmt.Errorf("process act output: %w", err)
-			return
+	execution, err := r.executor.Execute(ctx, args, os.Environ())
+	if err != nil {
+		if recording != nil {
+			_ = recording.Close()
+			_ = os.Remove(recording.Name())
 		}
-		errs <- nil
-	}()
-
-	// Wait for stdout processing to complete FIRST.
-	// The scanner will return EOF when the process exits and closes its stdout.
-	if streamErr := <-errs; streamErr != nil {
-		// Still call Wait to clean up the process
-		_ = cmd.Wait()
-		return nil, streamErr
+		return nil, fmt.Errorf("execute act: %w", err)
 	}
 
-	// Now wait for the process to fully exit and get its exit status.
-	if err := cmd.Wait(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() != 0 {
-			runResult.Success = false
-			return runResult, nil
+	// Process json logs in merged stdout/stderr stream.
+	// This must complete BEFORE waiting for act to exit, to make sure
+	// all the output has been consumed.
+	runResult.commands.masker.add(r.secretValues()...)
+	streamErr := r.processStream(execution.Output(), runResult, recordingWriter)
+
+	// Now wait for act to fully exit and get its exit status.
+	exitCode, waitErr := execution.Wait()
+	if recording != nil {
+		if err := finishRecording(recording, exitCode); err != nil {
+			return nil, fmt.Errorf("finish recording: %w", err)
+		}
+	}
+	if runID, err := runResult.GetTestingWorkflowRunID(); err == nil {
+		r.ArtifactsStorage.retentions.record(runID, artifactRetentions)
+	}
+	if ctx.Err() != nil {
+		// Killed because of cancellation or timeout: return what we have so far.
+		// Stream errors are expected here, since the output may have been closed forcibly.
+		// The job containers are not children of the act process, so they must be removed separately.
+		if err := removeJobContainers(workflow); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: remove act job containers: %v\n", r.name, err)
 		}
-		return nil, fmt.Errorf("act exit: %w", err)
+		runResult.Success = false
+		runResult.Cancelled = true
+		runResult.RunningJobs = runResult.runningJobs()
+		runResult.markCancelled()
+		return runResult, fmt.Errorf("act run interrupted: %w", ctx.Err())
+	}
+	if streamErr != nil {
+		return nil, fmt.Errorf("process act output: %w", streamErr)
+	}
+	if waitErr != nil {
+		return nil, fmt.Errorf("act exit: %w", waitErr)
+	}
+	runResult.markSkipped(workflow)
+	runResult.resolveJobOutputs(workflow)
+	runResult.Success = exitCode == 0
+	if runResult.Success {
+		// A matching log line in a successful run is not an infrastructure failure
+		runResult.InfraFailure = nil
+	} else if runResult.InfraFailure != nil {
+		runResult.InfraFailure.ExitCode = exitCode
 	}
-	runResult.Success = true
 	return runResult, nil
 }
 
+// removeJobContainers force-removes all the Docker containers that act created for the given workflow.
+// Container names are derived from the job names, which contain the testing workflow UUID
+// (see workflow.TestingWorkflow.AddUUIDToAllJobsRecursive). If the workflow has no UUID, it does nothing.
+func removeJobContainers(wf workflow.Workflow) error {
+	uuidWf, ok := wf.(interface{ UUID() uuid.UUID })
+	if !ok {
+		return nil
+	}
+	output, err := exec.Command("docker", "ps", "-aq", "--filter", "name="+uuidWf.UUID().String()).Output()
+	if err != nil {
+		return fmt.Errorf("list containers: %w", err)
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := exec.Command("docker", append([]string{"rm", "-f"}, ids...)...).Run(); err != nil {
+		return fmt.Errorf("remove containers %v: %w", ids, err)
+	}
+	return nil
+}
+
 // logOrBuffer writes a message to the buffer if running in GitHub Actions,
 // or prints it immediately to stdout otherwise.
 func (r *Runner) logOrBuffer(msg string, logBuffer *strings.Builder) {
@@ -417,30 +591,49 @@ func (r *Runner) logOrBuffer(msg string, logBuffer *strings.Builder) {
 // processStream processes the given reader line by line as JSON log lines generated by act.
 // If running in GitHub Actions, it buffers all log lines and prints them in a log group when the process finishes.
 // Otherwise, it prints each line immediately to stdout.
-func (r *Runner) processStream(reader io.Reader, runResult *RunResult) error {
+// Secret values are masked in the printed lines. If recording is not nil, each masked line is also written to it.
+func (r *Runner) processStream(reader io.Reader, runResult *RunResult, recording io.Writer) error {
 	scanner := bufio.NewScanner(reader)
 	var logBuffer strings.Builder
+	masker := runResult.commands.masker
 
 	for scanner.Scan() {
 		var data logLine
 		line := scanner.Bytes()
 		err := json.Unmarshal(line, &data)
+		if err == nil {
+			// Keep track of the jobs and steps timeline
+			runResult.trackJob(data)
+
+			// Clean up uuids from data.Job for cleaner output
+			data.Job = logUUIDRegex.ReplaceAllString(data.Job, "")
+
+			// Parse GHA commands (outputs, annotations, etc.).
+			// This must happen before printing the line, so values masked via add-mask are never printed.
+			r.parseGHACommand(data, runResult)
+		}
+		maskedLine := masker.maskJSON(line)
+		if recording != nil {
+			if _, err := io.WriteString(recording, maskedLine+"\n"); err != nil {
+				return fmt.Errorf("write recording: %w", err)
+			}
+		}
+		if err != nil {
+			// Plain-text lines are printed by act or Docker on stderr, and may report infrastructure failures
+			runResult.detectInfraFailure(maskedLine)
+		} else if isActLogMessage(data) {
+			runResult.detectInfraFailure(masker.mask(data.Message))
+		}
 		if err != nil {
 			// Preserve plain-text lines (commonly emitted on stderr) in non-verbose mode.
-			r.logOrBuffer(string(line), &logBuffer)
+			r.logOrBuffer(maskedLine, &logBuffer)
 			continue
 		}
 		if r.Verbose {
-			r.logOrBuffer(string(line), &logBuffer)
+			r.logOrBuffer(maskedLine, &logBuffer)
 		}
-
-		// Clean up uuids from data.Job for cleaner output
-		data.Job = logUUIDRegex.ReplaceAllString(data.Job, "")
 		formattedLog := fmt.Sprintf("%s: [%s] %s", r.name, data.Job, strings.TrimSpace(data.Message))
-		r.logOrBuffer(formattedLog, &logBuffer)
-
-		// Parse GHA commands (outputs, annotations, etc.)
-		r.parseGHACommand(data, runResult)
+		r.logOrBuffer(masker.mask(formattedLog), &logBuffer)
 	}
 	if err := scanner.Err(); err != nil {
 		return fmt.Errorf("scanner error: %w", err)
@@ -461,8 +654,18 @@ func (r *Runner) processStream(reader io.Reader, runResult *RunResult) error {
 // parseGHACommand parses intercepted GHA commands from act log lines and
 // updates the RunResult accordingly. If the log line does not contain a
 // recognized command, it is ignored.
+// Only the lines act reports as commands are parsed: act intercepts all the workflow commands printed by the steps,
+// and reports the ones inside a stop-commands block as ignored.
 // Warning and verbose messages are always printed immediately to stdout.
 func (r *Runner) parseGHACommand(data logLine, runResult *RunResult) {
+	if data.Command == "" || data.Command == ignoredCommand {
+		// Not a command: add the line to the open log group, if any
+		if group, ok := runResult.commands.openGroups[newJobLeg(data)]; ok {
+			group.Lines = append(group.Lines, runResult.commands.masker.mask(data.Message))
+		}
+		return
+	}
+
 	// Intercept custom "act-debug" command and treat it as a normal debug annotation.
 	// Normally, debug annotations are very verbose and not shown in act output unless --verbose is provided.
 	// We use this custom "act" command to selectively log debug information in our workflows whenever we need to,
@@ -471,28 +674,76 @@ func (r *Runner) parseGHACommand(data logLine, runResult *RunResult) {
 		data.Command = "debug"
 	}
 
+	// act passes the name either as a field or as a property, depending on the command
+	if data.Name == "" {
+		data.Name = data.KvPairs["name"]
+	}
+	stepID := topLevelStepID(data)
+	masker := runResult.commands.masker
+	// Record the command after handling it, so the value of add-mask is masked as well
+	defer func() {
+		runResult.Commands = append(runResult.Commands, WorkflowCommand{
+			JobID:   data.JobID,
+			StepID:  data.StepID,
+			Command: data.Command,
+			Name:    data.Name,
+			Value:   masker.mask(data.Arg),
+		})
+	}()
+
 	switch data.Command {
 	case "set-output":
 		if data.Name == "" {
 			fmt.Printf("%s: [%s]: WARNING: received GHA set-output command without name, ignoring output\n", r.name, data.Job)
 			break
 		}
-		// Store the output value. StepID can be an array in case of composite actions,
-		// group all composite action outputs under the first step ID for simplicity.
-		runResult.Outputs.Set(data.JobID, data.StepID[0], data.Name, data.Arg)
+		// Store the output value. StepID is the path of step IDs in case of composite actions:
+		// the output can be looked up by the full path or by the top-level step.
+		runResult.Outputs.SetMatrix(data.JobID, data.Matrix, StepPath(data.StepID...), data.Name, data.Arg)
 	case "debug", "notice", "warning", "error":
 		// Annotations
-		runResult.Annotations = append(runResult.Annotations, Annotation{
-			Level:   AnnotationLevel(data.Command),
-			Title:   data.KvPairs["title"],
-			Message: data.Arg,
-		})
+		// Annotations are shown in the GitHub UI, so secrets are masked like in the logs
+		annotation := newAnnotation(data, stepID)
+		annotation.Title, annotation.Message = masker.mask(annotation.Title), masker.mask(annotation.Message)
+		runResult.Annotations = append(runResult.Annotations, annotation)
 	case "summary":
 		// Summary
 		runResult.Summary = append(runResult.Summary, data.Content)
+		summary := ParseSummary(data.Content)
+		summary.JobID, summary.StepID = data.JobID, stepID
+		runResult.StepSummaries = append(runResult.StepSummaries, summary)
+	case "set-env":
+		if data.Name == "" {
+			fmt.Printf("%s: [%s]: WARNING: received GHA set-env command without name, ignoring env\n", r.name, data.Job)
+			break
+		}
+		runResult.setEnv(data.JobID, data.Matrix, data.Name, data.Arg)
+	case "add-path":
+		runResult.addPath(data.JobID, data.Matrix, data.Arg)
+	case "save-state":
+		if data.Name == "" {
+			fmt.Printf("%s: [%s]: WARNING: received GHA save-state command without name, ignoring state\n", r.name, data.Job)
+			break
+		}
+		runResult.State.SetMatrix(data.JobID, data.Matrix, StepPath(data.StepID...), data.Name, data.Arg)
+	case "add-mask":
+		runResult.Masks = append(runResult.Masks, data.Arg)
+		masker.add(data.Arg)
+	case "group":
+		group := &LogGroup{JobID: data.JobID, StepID: stepID, Title: data.Arg}
+		if len(data.Matrix) > 0 {
+			group.Matrix = data.Matrix
+		}
+		runResult.LogGroups = append(runResult.LogGroups, group)
+		runResult.commands.openGroups[newJobLeg(data)] = group
+	case "endgroup":
+		delete(runResult.commands.openGroups, newJobLeg(data))
+	case "echo", "stop-commands":
+		// Only recorded in Commands, since they don't change the result.
+		// act itself ignores the commands until the stop-commands token is used as a command.
 	default:
 		// Nothing special to do
-		if r.Verbose && data.Command != "" {
+		if r.Verbose {
 			fmt.Printf("%s: [%s]: unhandled GHA command %q, ignoring\n", r.name, data.Job, data.Command)
 		}
 	}
@@ -504,31 +755,29 @@ func checkExecutable(name string) bool {
 	return err == nil
 }
 
-// checkExecutables checks if all executables required by the Runner are available in PATH.
-func (r *Runner) checkExecutables() error {
-	for _, name := range []string{"act", "gh"} {
-		if !checkExecutable(name) {
-			return fmt.Errorf("%q executable not found", name)
-		}
-	}
-	return nil
-}
-
 // Outputs represents the outputs of jobs in a workflow run.
-// Callers should use the Get and Set methods to access outputs.
+// Callers should use the Get and Set methods to access outputs, or GetMatrix and SetMatrix for matrix jobs.
 type Outputs struct {
-	// data is a map of job id -> step id -> output name (keys) -> output value (value)
+	// data is a map of job id -> step id -> output name (keys) -> output value (value).
+	// Steps nested in composite actions are keyed by their path, and their outputs are set for the top-level step as well.
 	data map[string]map[string]map[string]string
+
+	// matrix is a map of job id -> matrix key (see Matrix.Key) -> outputs of the matrix leg
+	matrix map[string]map[string]*matrixOutputs
 }
 
 // newOutputs creates a new Outputs instance.
 func newOutputs() Outputs {
 	return Outputs{
-		data: make(map[string]map[string]map[string]string),
+		data:   make(map[string]map[string]map[string]string),
+		matrix: make(map[string]map[string]*matrixOutputs),
 	}
 }
 
 // Get retrieves the output value for the given job ID, step ID, and output name.
+// The step ID can be a top-level step, or the exact path of a step nested in composite actions (see StepPath).
+// The outputs of nested steps are also outputs of their top-level step: if several steps set the same output,
+// the top-level step has the last value set.
 func (o Outputs) Get(jobID, stepID, outputName string) (string, bool) {
 	if steps, ok := o.data[jobID]; ok {
 		if outputs, ok := steps[stepID]; ok {
@@ -541,18 +790,32 @@ func (o Outputs) Get(jobID, stepID, outputName string) (string, bool) {
 }
 
 // Set sets the output value for the given job ID, step ID, and output name.
+// If the step ID is the path of a step nested in composite actions (see StepPath),
+// the value is set for both the nested step and its top-level step.
 func (o Outputs) Set(jobID, stepID, outputName, value string) {
 	steps, ok := o.data[jobID]
 	if !ok {
 		steps = map[string]map[string]string{}
 		o.data[jobID] = steps
 	}
-	outputs, ok := steps[stepID]
-	if !ok {
-		outputs = map[string]string{}
-		steps[stepID] = outputs
+	setStepOutput(steps, stepID, outputName, value)
+}
+
+// setStepOutput sets the output value in the given step id -> output name -> output value map,
+// for the given step and, if the step is nested in composite actions, for its top-level step.
+func setStepOutput(steps map[string]map[string]string, stepID, outputName, value string) {
+	keys := []string{stepID}
+	if topLevel, _, nested := strings.Cut(stepID, stepPathSeparator); nested {
+		keys = append(keys, topLevel)
+	}
+	for _, key := range keys {
+		outputs, ok := steps[key]
+		if !ok {
+			outputs = map[string]string{}
+			steps[key] = outputs
+		}
+		outputs[outputName] = value
 	}
-	outputs[outputName] = value
 }
 
 // RunResult represents the result of a test workflow that was run via act.
@@ -563,46 +826,101 @@ type RunResult struct {
 	// Outputs contains the outputs for each job + step of the workflow run.
 	Outputs Outputs
 
-	// Annotations contains the GitHub Actions annotations generated during the workflow run.
-	Annotations []Annotation
+	// JobOutputs contains the outputs of the jobs that ran: job id -> output name -> value.
+	// The jobs of reusable workflows are keyed by their path (see JobPath), e.g.: "cd/ci/build".
+	// They're evaluated from the outputs declared by the jobs in the workflow and its children, once the run completes.
+	// For jobs calling a reusable workflow, they're the workflow_call outputs declared by the reusable workflow.
+	// Outputs whose value uses expressions other than references to step and job outputs are not included.
+	// act reports the step outputs by job ID only, so the jobs with the same ID in different reusable workflows
+	// share their step outputs: if they set the same step output, they all get the value reported last.
+	JobOutputs map[string]map[string]string
+
+	// Annotations contains the GitHub Actions annotations generated during the workflow run, in order.
+	// Secret values are masked in their title and message, like in the GitHub UI.
+	Annotations Annotations
 
 	// Summary contains the summary of the workflow run.
 	Summary []string
-}
 
-// AnnotationLevel represents the level of a GitHub Actions annotation.
-type AnnotationLevel string
+	// StepSummaries contains the summaries written by the steps of the workflow run, in order,
+	// parsed into headings, tables, lists and code blocks. See ParseSummary.
+	StepSummaries []StepSummary
 
-// Annotation levels
-const (
-	AnnotationLevelDebug   AnnotationLevel = "debug"
-	AnnotationLevelNotice  AnnotationLevel = "notice"
-	AnnotationLevelWarning AnnotationLevel = "warning"
-	AnnotationLevelError   AnnotationLevel = "error"
-)
+	// Cancelled indicates whether the workflow run was interrupted before act exited,
+	// because the context was cancelled or the Runner's timeout expired.
+	Cancelled bool
+
+	// RunningJobs contains the IDs of the jobs that were still running when the workflow run was interrupted.
+	// It is only populated if Cancelled is true. Those jobs have ConclusionCancelled in Jobs.
+	RunningJobs []string
+
+	// Jobs contains the timeline and the conclusion of each job (and its steps) of the workflow run, by job ID.
+	Jobs map[string]*JobResult
 
-// Annotation represents a single GitHub Actions annotation.
-type Annotation struct {
-	// Level is the level of the annotation.
-	Level AnnotationLevel
+	// Commands contains all the GitHub Actions workflow commands intercepted during the workflow run, in order.
+	Commands []WorkflowCommand
 
-	// Title is the optional title of the annotation.
-	Title string
+	// Env contains the environment variables exported via set-env or GITHUB_ENV:
+	// job id -> variable name -> last value.
+	// For matrix jobs, it contains the variables of all the legs, so the last leg wins (see MatrixEnv).
+	Env map[string]map[string]string
 
-	// Message is the message of the annotation itself.
-	Message string
+	// MatrixEnv contains the environment variables exported by each leg of the matrix jobs:
+	// job id -> matrix key (see Matrix.Key) -> variable name -> last value.
+	MatrixEnv map[string]map[string]map[string]string
+
+	// Paths contains the paths added to PATH via add-path or GITHUB_PATH, in order, by job id.
+	// For matrix jobs, it contains the paths of all the legs (see MatrixPaths).
+	Paths map[string][]string
+
+	// MatrixPaths contains the paths added to PATH by each leg of the matrix jobs, in order:
+	// job id -> matrix key (see Matrix.Key) -> paths.
+	MatrixPaths map[string]map[string][]string
+
+	// State contains the state saved via the save-state command (act doesn't report the GITHUB_STATE file).
+	// It uses the same job id -> step id -> name structure as Outputs.
+	State Outputs
+
+	// Masks contains the values masked via add-mask, in order.
+	Masks []string
+
+	// LogGroups contains the log groups opened via the group command, in order.
+	// Secret values are masked in the log lines.
+	LogGroups []*LogGroup
+
+	// Attempts is the number of times act was run, including retries of infrastructure failures. See RetryPolicy.
+	Attempts int
+
+	// QueueTime is the time the run waited to be admitted by the Scheduler. It doesn't count toward the timeout.
+	QueueTime time.Duration
+
+	// InfraFailure is set if the run failed because of the infrastructure rather than because of the workflow.
+	// It's the first infrastructure failure detected in act's log lines. See RetryPolicy.
+	InfraFailure *InfraError
+
+	// commands keeps the state of the workflow commands that span multiple log lines.
+	commands *commandsState
 }
 
 // newRunResult creates a new empty RunResult instance.
 func newRunResult() RunResult {
-	return RunResult{Outputs: newOutputs()}
+	return RunResult{
+		Outputs:     newOutputs(),
+		Jobs:        map[string]*JobResult{},
+		Env:         map[string]map[string]string{},
+		MatrixEnv:   map[string]map[string]map[string]string{},
+		Paths:       map[string][]string{},
+		MatrixPaths: map[string]map[string][]string{},
+		State:       newOutputs(),
+		commands:    newCommandsState(),
+	}
 }
 
 // GetTestingWorkflowRunID retrieves the GitHub Actions workflow run ID.
 // For this to work, the workflow must have been created via NewTestingWorkflow,
 // which adds a job to get the run ID and expose it as an output.
 func (r *RunResult) GetTestingWorkflowRunID() (string, error) {
-	runID, ok := r.Outputs.Get("get-workflow-run-id", "run-id", "run-id")
+	runID, ok := r.Outputs.Get(getWorkflowRunIDJobID, "run-id", "run-id")
 	if !ok {
 		return "", errors.New("could not get workflow run id. make sure you created the testing workflow via NewTestingWorkflow")
 	}
@@ -611,11 +929,15 @@ func (r *RunResult) GetTestingWorkflowRunID() (string, error) {
 
 // containerOptions returns the Docker container options for act.
 // On Linux, it adds --add-host to enable host.docker.internal (already works on Docker Desktop for macOS/Windows).
-func (r *Runner) containerOptions() string {
-
+// The options are a single act argument, which act splits into Docker options itself.
+func (r *Runner) containerOptions() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("get working directory: %w", err)
+	}
 	opts := []string{
 		// mocked testdata, dist artifacts
-		"-v $PWD/tests/act/mockdata:/mockdata",
+		"-v " + filepath.Join(wd, "tests", "act", "mockdata") + ":/mockdata",
 		// mocked GCS
 		"-v " + r.GCS.basePath + ":/gcs",
 	}
@@ -628,7 +950,7 @@ func (r *Runner) containerOptions() string {
 		}
 	}
 
-	return `"` + strings.Join(opts, " ") + `"`
+	return strings.Join(opts, " "), nil
 }
 
 // getDockerHostIP returns the IP address that Docker containers can use to reach the host.
@@ -711,53 +1033,6 @@ func markPortAsFree(port int) {
 	takenPorts.Delete(port)
 }
 
-// copyDir recursively copies a directory tree from src to dst.
-// If src does not exist, it creates an empty dst directory.
-func copyDir(src, dst string) error {
-	// Check if source exists
-	srcInfo, err := os.Stat(src)
-	if os.IsNotExist(err) {
-		// Source doesn't exist, just create an empty destination directory
-		return os.MkdirAll(dst, 0755)
-	}
-	if err != nil {
-		return fmt.Errorf("stat source: %w", err)
-	}
-	if !srcInfo.IsDir() {
-		return fmt.Errorf("source is not a directory: %s", src)
-	}
-
-	// Create destination directory
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
-		return fmt.Errorf("create destination directory: %w", err)
-	}
-
-	// Walk through source directory and copy all files
-	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Calculate relative path and destination path
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return fmt.Errorf("get relative path: %w", err)
-		}
-		dstPath := filepath.Join(dst, relPath)
-
-		if d.IsDir() {
-			info, err := d.Info()
-			if err != nil {
-				return fmt.Errorf("get dir info: %w", err)
-			}
-			return os.MkdirAll(dstPath, info.Mode())
-		}
-
-		// Copy file
-		return copyFile(path, dstPath)
-	})
-}
-
 // copyFile copies a single file from src to dst, preserving permissions.
 func copyFile(src, dst string) (err error) {
 	srcFile, err := os.Open(src)
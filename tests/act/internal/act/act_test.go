@@ -0,0 +1,540 @@
+package act
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRunner creates a Runner that uses the given Executor instead of the act CLI.
+// The current working directory is changed to the root of the repository for the duration of the test,
+// like for the act tests, so the Runner can find the release-please files and the workflows folder.
+// Scheduling is disabled, since no act containers are started.
+func newTestRunner(t *testing.T, executor Executor, opts ...RunnerOption) *Runner {
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	t.Chdir(filepath.Join("..", "..", "..", ".."))
+	r, err := NewRunner(t, append([]RunnerOption{WithExecutor(executor), WithScheduler(nil)}, opts...)...)
+	require.NoError(t, err)
+	return r
+}
+
+// newTestWorkflow creates a TestingWorkflow with a single "build" job with the given steps.
+func newTestWorkflow(steps ...workflow.Step) *workflow.TestingWorkflow {
+	wf := workflow.NewTestingWorkflow("unit-test", workflow.BaseWorkflow{
+		Name: "Unit test",
+		Jobs: map[string]*workflow.Job{
+			"build": {
+				Name:   "Build",
+				RunsOn: "ubuntu-arm64-small",
+				Steps:  steps,
+			},
+		},
+	})
+	return wf
+}
+
+// jsonLogLine returns the given logLine as a JSON log line, as act would print it.
+func jsonLogLine(t *testing.T, l logLine) string {
+	b, err := json.Marshal(l)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestRunnerArgs(t *testing.T) {
+	r := newTestRunner(t, &ScriptedExecutor{})
+	args, port, err := r.args(EventKindPush, "some-actor", "workflow.yml", "payload.json")
+	require.NoError(t, err)
+	defer markPortAsFree(port)
+
+	require.Equal(t, string(EventKindPush), args[0], "first argument should be the event kind")
+	require.Subset(t, args, []string{"-W", "workflow.yml", "-e", "payload.json", "--rm", "--json"})
+	require.Contains(t, args, "GITHUB_TOKEN=test-token")
+	require.Contains(t, args, "some-actor")
+}
+
+func TestRunnerArgsErrorFreesPort(t *testing.T) {
+	countTakenPorts := func() int {
+		n := 0
+		takenPorts.Range(func(_, _ any) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	r := newTestRunner(t, &ScriptedExecutor{}, WithActionsCachePath(TemplateActionsCachePath))
+	taken := countTakenPorts()
+	// There is no release-please config outside the repository, so the local repository args can't be built
+	t.Chdir(t.TempDir())
+	_, _, err := r.args(EventKindPush, "", "workflow.yml", "payload.json")
+	require.ErrorContains(t, err, "release-please-config.json")
+	require.Equal(t, taken, countTakenPorts(), "the artifact server port should be marked as free")
+}
+
+func TestRunnerLocalRepositoryArgs(t *testing.T) {
+	r := newTestRunner(t, &ScriptedExecutor{})
+	args, err := r.localRepositoryArgs()
+	require.NoError(t, err)
+
+	root, err := os.Getwd()
+	require.NoError(t, err)
+	require.Contains(t, args, "--local-repository=grafana/plugin-ci-workflows@main="+root)
+
+	// One mapping per release-please component, plus main
+	var manifest map[string]string
+	manifestContent, err := os.ReadFile(".release-please-manifest.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(manifestContent, &manifest))
+	require.Len(t, args, len(manifest)+1)
+}
+
+func TestRunnerRunContext(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	line := func(t *testing.T, offset int, l logLine) string {
+		l.JobID = "build"
+		l.Job = "Build-00000000-0000-0000-0000-000000000000"
+		l.Time = start.Add(time.Duration(offset) * time.Second)
+		return jsonLogLine(t, l)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		executor := &ScriptedExecutor{
+			Output: []string{
+				"plain text line that is not json",
+				line(t, 0, logLine{Message: "Starting job"}),
+				line(t, 1, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, Message: "Run Main Hello"}),
+				line(t, 2, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, Command: "set-output", Name: "greeting", Arg: "hello world"}),
+				line(t, 2, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, Command: "warning", Arg: "be careful", KvPairs: map[string]string{"title": "Careful"}}),
+				line(t, 2, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, Command: "summary", Content: "# Summary"}),
+				line(t, 3, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, StepResult: "success"}),
+				line(t, 4, logLine{JobResult: "success"}),
+			},
+		}
+		r := newTestRunner(t, executor)
+		wf := newTestWorkflow(
+			workflow.Step{ID: "hello", Name: "Hello", Run: "echo hello"},
+			workflow.Step{ID: "never", Name: "Never", If: "false", Run: "echo never"},
+		)
+
+		res, err := r.Run(wf, NewPushEventPayload("main"))
+		require.NoError(t, err)
+		require.True(t, res.Success)
+		require.False(t, res.Cancelled)
+
+		calls := executor.Calls()
+		require.Len(t, calls, 1)
+		require.Contains(t, calls[0], filepath.Join(".github", "workflows", wf.FileName()))
+
+		greeting, ok := res.Outputs.Get("build", "hello", "greeting")
+		require.True(t, ok)
+		require.Equal(t, "hello world", greeting)
+		require.Equal(t, Annotations{{Level: AnnotationLevelWarning, Title: "Careful", Message: "be careful", JobID: "build", StepID: "hello"}}, res.Annotations)
+		require.Equal(t, []string{"# Summary"}, res.Summary)
+		summary, ok := res.StepSummary("build", "hello")
+		require.True(t, ok)
+		require.Equal(t, []Heading{{Level: 1, Text: "Summary"}}, summary.Headings)
+
+		// Timeline
+		require.Contains(t, res.Jobs, "build")
+		job := res.Jobs["build"]
+		require.Equal(t, "Build", job.Name)
+		require.Equal(t, ConclusionSuccess, job.Conclusion)
+		require.Equal(t, 4*time.Second, job.Duration())
+		require.Len(t, job.Steps, 2)
+		require.Equal(t, ConclusionSuccess, job.Step("hello").Conclusion)
+		require.Equal(t, 2*time.Second, job.Step("hello").Duration())
+		require.Equal(t, ConclusionSkipped, job.Step("never").Conclusion)
+
+		// The job added by NewTestingWorkflow never ran
+		require.Contains(t, res.Jobs, "get-workflow-run-id")
+		require.Equal(t, ConclusionSkipped, res.Jobs["get-workflow-run-id"].Conclusion)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{
+			Output: []string{
+				line(t, 0, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, StepResult: "failure"}),
+				line(t, 1, logLine{JobResult: "failure"}),
+			},
+			ExitCode: 1,
+		})
+		res, err := r.Run(newTestWorkflow(workflow.Step{ID: "hello", Run: "exit 1"}), NewPushEventPayload("main"))
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Equal(t, ConclusionFailure, res.Jobs["build"].Conclusion)
+		require.Equal(t, ConclusionFailure, res.Jobs["build"].Step("hello").Conclusion)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{
+			Output: []string{
+				line(t, 0, logLine{Stage: "Main", Step: "Hello", StepID: []string{"hello"}, Message: "Run Main Hello"}),
+			},
+			Block: true,
+		}, WithTimeout(50*time.Millisecond))
+		res, err := r.RunContext(context.Background(), newTestWorkflow(workflow.Step{ID: "hello", Run: "sleep infinity"}), NewPushEventPayload("main"))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotNil(t, res, "should return a partial result")
+		require.False(t, res.Success)
+		require.True(t, res.Cancelled)
+		require.Equal(t, []string{"build"}, res.RunningJobs)
+		require.Equal(t, ConclusionCancelled, res.Jobs["build"].Conclusion)
+		require.Equal(t, ConclusionCancelled, res.Jobs["build"].Step("hello").Conclusion)
+	})
+}
+
+func TestParseGHACommand(t *testing.T) {
+	r := &Runner{name: t.Name()}
+	for _, tc := range []struct {
+		name   string
+		line   logLine
+		assert func(t *testing.T, res *RunResult)
+	}{
+		{
+			name: "set-output in composite action",
+			line: logLine{JobID: "build", StepID: []string{"setup", "0"}, Command: "set-output", Name: "version", Arg: "1.0.0"},
+			assert: func(t *testing.T, res *RunResult) {
+				v, ok := res.Outputs.Get("build", "setup", "version")
+				require.True(t, ok)
+				require.Equal(t, "1.0.0", v)
+				v, ok = res.Outputs.Get("build", StepPath("setup", "0"), "version")
+				require.True(t, ok)
+				require.Equal(t, "1.0.0", v)
+				_, ok = res.Outputs.Get("build", StepPath("setup", "1"), "version")
+				require.False(t, ok)
+			},
+		},
+		{
+			name: "set-output without name is ignored",
+			line: logLine{JobID: "build", StepID: []string{"setup"}, Command: "set-output", Arg: "1.0.0"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Empty(t, res.Outputs.data)
+			},
+		},
+		{
+			name: "act-debug is a debug annotation",
+			line: logLine{JobID: "build", Command: "act-debug", Arg: "msg=hello"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, Annotations{{Level: AnnotationLevelDebug, Message: "msg=hello", JobID: "build"}}, res.Annotations)
+			},
+		},
+		{
+			name: "error annotation",
+			line: logLine{JobID: "build", Command: "error", Arg: "boom", KvPairs: map[string]string{"title": "Oops"}},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, Annotations{{Level: AnnotationLevelError, Title: "Oops", Message: "boom", JobID: "build"}}, res.Annotations)
+			},
+		},
+		{
+			name: "annotation with location",
+			line: logLine{
+				JobID: "lint", StepID: []string{"eslint", "0"}, Command: "warning", Arg: "unused variable",
+				KvPairs: map[string]string{"file": "src/module.ts", "line": "12", "endLine": "14", "col": "3", "endColumn": "not-a-number"},
+			},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, Annotations{{
+					Level: AnnotationLevelWarning, Message: "unused variable", JobID: "lint", StepID: "eslint",
+					File: "src/module.ts", Line: 12, EndLine: 14, Col: 3,
+				}}, res.Annotations)
+			},
+		},
+		{
+			name: "summary",
+			line: logLine{JobID: "build", Command: "summary", Content: "## Hello"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, []string{"## Hello"}, res.Summary)
+			},
+		},
+		{
+			name: "set-env with name property",
+			line: logLine{JobID: "build", StepID: []string{"setup"}, Command: "set-env", Arg: "1.25", KvPairs: map[string]string{"name": "GO_VERSION"}},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, map[string]map[string]string{"build": {"GO_VERSION": "1.25"}}, res.Env)
+			},
+		},
+		{
+			name: "set-env from GITHUB_ENV",
+			line: logLine{JobID: "build", StepID: []string{"paths"}, Command: "set-env", Name: "GCS_PATH", Arg: "bucket/a=b", Message: "  \u2699  ::set-env:: GCS_PATH=bucket/a=b"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, map[string]map[string]string{"build": {"GCS_PATH": "bucket/a=b"}}, res.Env)
+			},
+		},
+		{
+			name: "add-path",
+			line: logLine{JobID: "build", StepID: []string{"setup"}, Command: "add-path", Arg: "/opt/go/bin"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, map[string][]string{"build": {"/opt/go/bin"}}, res.Paths)
+			},
+		},
+		{
+			name: "save-state",
+			line: logLine{JobID: "build", StepID: []string{"cache", "1"}, Command: "save-state", Name: "key", Arg: "abc"},
+			assert: func(t *testing.T, res *RunResult) {
+				v, ok := res.State.Get("build", "cache", "key")
+				require.True(t, ok)
+				require.Equal(t, "abc", v)
+			},
+		},
+		{
+			name: "add-mask",
+			line: logLine{JobID: "build", Command: "add-mask", Arg: "s3cr3t"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Equal(t, []string{"s3cr3t"}, res.Masks)
+			},
+		},
+		{
+			name: "raw workflow command",
+			line: logLine{JobID: "build", StepID: []string{"hello"}, Message: "::set-output name=greeting::hello", RawOutput: true},
+			assert: func(t *testing.T, res *RunResult) {
+				// act intercepts all workflow commands itself, so raw lines are never parsed
+				require.Empty(t, res.Commands)
+				require.Empty(t, res.Outputs.data)
+			},
+		},
+		{
+			name: "ignored command",
+			line: logLine{JobID: "build", StepID: []string{"hello"}, Command: "ignored", Message: "  \u2699  ::set-output name=greeting::hello\n"},
+			assert: func(t *testing.T, res *RunResult) {
+				require.Empty(t, res.Commands)
+				require.Empty(t, res.Outputs.data)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := newRunResult()
+			r.parseGHACommand(tc.line, &res)
+			tc.assert(t, &res)
+		})
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixtures", "run.log")
+	executor := &ScriptedExecutor{
+		Output: []string{
+			"plain text line",
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Command: "set-output", Name: "greeting", Arg: "hello"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", Command: "error", Arg: "boom"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", JobResult: "failure"}),
+		},
+		ExitCode: 1,
+	}
+	recordingRunner := newTestRunner(t, executor, WithRecording(fixture))
+	wf := newTestWorkflow(workflow.Step{ID: "hello", Run: "echo hello"})
+	recorded, err := recordingRunner.Run(wf, NewPushEventPayload("main"))
+	require.NoError(t, err)
+	require.False(t, recorded.Success)
+
+	content, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+	lines, exitCode, err := parseRecording(content)
+	require.NoError(t, err)
+	require.Equal(t, executor.Output, lines, "recording should contain the raw output")
+	require.Equal(t, 1, exitCode)
+
+	// Replays don't need GitHub credentials
+	t.Setenv("GITHUB_TOKEN", "")
+	replayRunner, err := NewReplayRunner(t, fixture)
+	require.NoError(t, err)
+	replayed, err := replayRunner.Run(wf, NewPushEventPayload("main"))
+	require.NoError(t, err)
+	// The internal parsing state differs, since the replay runner has no token to mask
+	recorded.commands, replayed.commands = nil, nil
+	require.Equal(t, recorded, replayed, "replayed result should be the same as the recorded one")
+}
+
+func TestRecordingCreateError(t *testing.T) {
+	// The parent of the fixture is a file, so the recording can't be created
+	parent := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(parent, nil, 0o644))
+	executor := &ScriptedExecutor{Block: true}
+	r := newTestRunner(t, executor, WithRecording(filepath.Join(parent, "run.log")))
+	_, err := r.Run(newTestWorkflow(workflow.Step{ID: "hello", Run: "echo hello"}), NewPushEventPayload("main"))
+	require.ErrorContains(t, err, "create recording")
+	require.Empty(t, executor.Calls(), "act should not be started if the recording can't be created")
+}
+
+func TestRecordAndReplayMaskedValues(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "run.log")
+	executor := &ScriptedExecutor{
+		Output: []string{
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Command: "set-output", Name: "greeting", Arg: "hello"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Command: "set-output", Name: "done", Arg: "true"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Command: "group", Arg: "Group"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Message: "job done: true", RawOutput: true}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Command: "endgroup"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Command: "warning", Arg: "job warning"}),
+			jsonLogLine(t, logLine{JobID: "build", Job: "Build", JobResult: "success"}),
+		},
+	}
+	// The masked values are also JSON keys and literals of the log lines, which must be left untouched
+	opts := []RunnerOption{WithMaskedValues("job", "true")}
+	recordingRunner := newTestRunner(t, executor, append(opts, WithRecording(fixture))...)
+	wf := newTestWorkflow(workflow.Step{ID: "hello", Run: "echo hello"})
+	recorded, err := recordingRunner.Run(wf, NewPushEventPayload("main"))
+	require.NoError(t, err)
+	require.True(t, recorded.Success)
+
+	content, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+	lines, _, err := parseRecording(content)
+	require.NoError(t, err)
+	for _, line := range lines {
+		require.True(t, json.Valid([]byte(line)), "recorded line should be valid JSON: %s", line)
+	}
+	require.NotContains(t, string(content), "job done")
+
+	replayRunner, err := NewReplayRunner(t, fixture, opts...)
+	require.NoError(t, err)
+	replayed, err := replayRunner.Run(wf, NewPushEventPayload("main"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"*** done: ***"}, replayed.LogGroups[0].Lines)
+	require.Equal(t, "*** warning", replayed.Annotations[0].Message)
+	// Outputs are not masked in the live run, but they are recorded masked
+	done, _ := recorded.Outputs.Get("build", "hello", "done")
+	require.Equal(t, "true", done)
+	done, _ = replayed.Outputs.Get("build", "hello", "done")
+	require.Equal(t, "***", done)
+	replayed.Outputs = recorded.Outputs
+	recorded.commands, replayed.commands = nil, nil
+	require.Equal(t, recorded, replayed, "replayed result should be the same as the recorded one")
+}
+
+func TestParseGHACommandMultiLine(t *testing.T) {
+	r := &Runner{name: t.Name()}
+	parse := func(lines ...logLine) *RunResult {
+		res := newRunResult()
+		for _, l := range lines {
+			l.JobID = "build"
+			l.StepID = []string{"hello"}
+			r.parseGHACommand(l, &res)
+		}
+		return &res
+	}
+
+	t.Run("log groups", func(t *testing.T) {
+		res := parse(
+			logLine{Message: "outside"},
+			logLine{Command: "group", Arg: "My group"},
+			logLine{Message: "line 1"},
+			logLine{Message: "line 2"},
+			logLine{Command: "endgroup"},
+			logLine{Message: "outside again"},
+		)
+		require.Equal(t, []*LogGroup{{JobID: "build", StepID: "hello", Title: "My group", Lines: []string{"line 1", "line 2"}}}, res.LogGroups)
+	})
+
+	t.Run("stop-commands", func(t *testing.T) {
+		res := parse(
+			logLine{Command: "group", Arg: "My group"},
+			logLine{Command: "stop-commands", Arg: "pause-token"},
+			logLine{Command: "ignored", Message: "  \u2699  ::set-output name=ignored::value\n"},
+			logLine{Command: "pause-token"},
+			logLine{Command: "set-output", Name: "processed", Arg: "value"},
+		)
+		_, ok := res.Outputs.Get("build", "hello", "ignored")
+		require.False(t, ok, "ignored commands should not be processed")
+		_, ok = res.Outputs.Get("build", "hello", "processed")
+		require.True(t, ok, "commands should be processed after resuming")
+		require.Equal(t, []string{"  \u2699  ::set-output name=ignored::value\n"}, res.LogGroups[0].Lines, "ignored commands should be logged")
+	})
+}
+
+func TestParseGHACommandActLog(t *testing.T) {
+	// The fixture follows the JSON log format of act v0.2.89 (see pkg/runner/command.go):
+	// act intercepts the workflow commands itself and reports them in the command, arg and kvPairs fields.
+	fixture, err := filepath.Abs(filepath.Join("testdata", "workflow-commands.log"))
+	require.NoError(t, err)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Chdir(filepath.Join("..", "..", "..", ".."))
+	r, err := NewReplayRunner(t, fixture)
+	require.NoError(t, err)
+	res, err := r.Run(newTestWorkflow(workflow.Step{ID: "vars", Name: "Vars", Run: "./vars.sh"}), NewPushEventPayload("main"))
+	require.NoError(t, err)
+	require.True(t, res.Success)
+
+	version, ok := res.Outputs.Get("build", "vars", "version")
+	require.True(t, ok)
+	require.Equal(t, "1.2.3", version)
+	_, ok = res.Outputs.Get("build", "vars", "ignored")
+	require.False(t, ok, "commands inside a stop-commands block should not be processed")
+
+	commands := make([]string, 0, len(res.Commands))
+	for _, c := range res.Commands {
+		commands = append(commands, c.Command)
+	}
+	require.Equal(t, []string{"set-output", "stop-commands", "pause-token", "unknown-cmd", "warning", "add-mask"}, commands)
+	require.Equal(t, Annotations{{
+		Level: AnnotationLevelWarning, Message: "be careful", JobID: "build", StepID: "vars", File: "app.go", Line: 3,
+	}}, res.Annotations)
+	require.Equal(t, []string{"s3cr3t"}, res.Masks)
+	require.Equal(t, ConclusionSuccess, res.Jobs["build"].Step("vars").Conclusion)
+}
+
+func TestRunnerCleanup(t *testing.T) {
+	var r *Runner
+	var wf *workflow.TestingWorkflow
+	t.Run("run", func(t *testing.T) {
+		r = newTestRunner(t, &ScriptedExecutor{})
+		wf = newTestWorkflow()
+		child := workflow.NewTestingWorkflow("child", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{}})
+		grandchild := workflow.NewTestingWorkflow("grandchild", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{}})
+		child.AddChild("grandchild", grandchild)
+		wf.AddChild("child", child)
+
+		_, err := r.Run(wf, NewPushEventPayload("main"))
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(r.ArtifactsStorage.basePath, 0o755))
+		for _, w := range []workflow.Workflow{wf, child, grandchild} {
+			require.FileExists(t, filepath.Join(".github", "workflows", w.FileName()))
+		}
+		require.DirExists(t, r.GCS.basePath)
+		require.DirExists(t, r.actionsCachePath)
+	})
+
+	// Everything is removed when the test ends
+	t.Chdir(filepath.Join("..", "..", "..", ".."))
+	for _, w := range append([]*workflow.TestingWorkflow{wf}, wf.ChildrenRecursive()...) {
+		require.NoFileExists(t, filepath.Join(".github", "workflows", w.FileName()))
+	}
+	require.NoDirExists(t, r.ArtifactsStorage.basePath)
+	require.NoDirExists(t, r.GCS.basePath)
+	require.NoDirExists(t, r.actionsCachePath)
+}
+
+func TestNestedStepOutputs(t *testing.T) {
+	r := &Runner{name: t.Name()}
+	res := newRunResult()
+	for _, l := range []logLine{
+		{JobID: "build", StepID: []string{"package", "zip"}, Command: "set-output", Name: "path", Arg: "plugin.zip"},
+		{JobID: "build", StepID: []string{"package", "sign", "zip"}, Command: "set-output", Name: "path", Arg: "plugin-signed.zip"},
+		{JobID: "build", StepID: []string{"package", "sign"}, Command: "save-state", Name: "signed", Arg: "true"},
+	} {
+		r.parseGHACommand(l, &res)
+	}
+
+	for _, tc := range []struct {
+		stepID string
+		exp    string
+	}{
+		{stepID: StepPath("package", "zip"), exp: "plugin.zip"},
+		{stepID: StepPath("package", "sign", "zip"), exp: "plugin-signed.zip"},
+		// The top-level step has the last value set by its nested steps
+		{stepID: "package", exp: "plugin-signed.zip"},
+	} {
+		v, ok := res.Outputs.Get("build", tc.stepID, "path")
+		require.True(t, ok, tc.stepID)
+		require.Equal(t, tc.exp, v, tc.stepID)
+	}
+	_, ok := res.Outputs.Get("build", StepPath("package", "sign"), "path")
+	require.False(t, ok, "intermediate composite steps only have their own outputs")
+
+	v, ok := res.State.Get("build", StepPath("package", "sign"), "signed")
+	require.True(t, ok)
+	require.Equal(t, "true", v)
+}
@@ -0,0 +1,281 @@
+package act
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// actionsCacheStore is a content-addressed store for the files of the template actions cache.
+// Each unique file is stored once in the objects directory, named after the SHA-256 of its content.
+// The objects directory is private to the store, so concurrent test processes sharing the same
+// objects base directory never remove each other's objects.
+// Per-runner actions caches are checked out from the store using reflinks, where supported.
+// Otherwise, immutable git objects are hardlinked and all the other files are copied,
+// so act can never modify the template actions cache via a per-runner one.
+type actionsCacheStore struct {
+	// templatePath is the path of the template actions cache, populated by the cache warmup.
+	templatePath string
+
+	// objectsBasePath is the directory where the objects directory is created.
+	// It can be shared by multiple processes.
+	objectsBasePath string
+
+	mu sync.Mutex
+
+	// objectsPath is the directory containing the content-addressed files.
+	// It's created in objectsBasePath while at least one per-runner actions cache is checked out.
+	objectsPath string
+
+	// entries are the files and directories of the template actions cache, built once on first checkout.
+	entries []actionsCacheEntry
+
+	// refs is the number of per-runner actions caches currently checked out.
+	// When it drops to zero, the objects directory is removed.
+	refs int
+}
+
+// actionsCacheEntry is a file, directory or symlink in the template actions cache.
+type actionsCacheEntry struct {
+	// path is the path relative to the root of the actions cache.
+	path string
+
+	// mode is the file mode of the entry.
+	mode fs.FileMode
+
+	// hash is the SHA-256 of the content of regular files.
+	hash string
+
+	// target is the target of symlinks.
+	target string
+}
+
+// defaultActionsCacheStore is the process-wide actionsCacheStore for TemplateActionsCachePath.
+var defaultActionsCacheStore = newActionsCacheStore(TemplateActionsCachePath, actionsCachePathBase)
+
+// newActionsCacheStore creates a new actionsCacheStore for the given template actions cache,
+// storing its objects in a new directory in objectsBasePath.
+func newActionsCacheStore(templatePath, objectsBasePath string) *actionsCacheStore {
+	return &actionsCacheStore{templatePath: templatePath, objectsBasePath: objectsBasePath}
+}
+
+// checkout populates dst with the content of the template actions cache.
+// The caller must call the returned release function when dst is no longer needed,
+// which removes dst if it was created by checkout.
+func (s *actionsCacheStore) checkout(dst string) (release func() error, err error) {
+	entries, err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(dst)
+	created := errors.Is(statErr, fs.ErrNotExist)
+	release = func() error {
+		var removeErr error
+		if created {
+			removeErr = os.RemoveAll(dst)
+		}
+		return errors.Join(removeErr, s.release())
+	}
+	if err := s.materialize(entries, dst); err != nil {
+		return nil, errors.Join(fmt.Errorf("checkout actions cache: %w", err), release())
+	}
+	return release, nil
+}
+
+// acquire increments the reference count of the store and returns its entries.
+// On the first reference, the entries are indexed (only once) and the objects directory is created and populated.
+func (s *actionsCacheStore) acquire() ([]actionsCacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		entries, err := s.index()
+		if err != nil {
+			return nil, fmt.Errorf("index actions cache: %w", err)
+		}
+		s.entries = entries
+	}
+	if s.refs == 0 {
+		if err := s.populateObjects(); err != nil {
+			if s.objectsPath != "" {
+				err = errors.Join(err, os.RemoveAll(s.objectsPath))
+				s.objectsPath = ""
+			}
+			return nil, fmt.Errorf("populate actions cache objects: %w", err)
+		}
+	}
+	s.refs++
+	return s.entries, nil
+}
+
+// release decrements the reference count of the store.
+// When it drops to zero, the objects directory is removed.
+func (s *actionsCacheStore) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	objectsPath := s.objectsPath
+	s.objectsPath = ""
+	if err := os.RemoveAll(objectsPath); err != nil {
+		return fmt.Errorf("remove actions cache objects: %w", err)
+	}
+	return nil
+}
+
+// index walks the template actions cache and returns its entries, hashing the content of regular files.
+// If the template actions cache doesn't exist, an empty (non-nil) list is returned.
+func (s *actionsCacheStore) index() ([]actionsCacheEntry, error) {
+	entries := []actionsCacheEntry{}
+	if _, err := os.Stat(s.templatePath); errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	err := filepath.WalkDir(s.templatePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(s.templatePath, path)
+		if err != nil {
+			return fmt.Errorf("get relative path: %w", err)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("get file info: %w", err)
+		}
+		entry := actionsCacheEntry{path: relPath, mode: info.Mode()}
+		switch {
+		case d.IsDir():
+		case d.Type()&fs.ModeSymlink != 0:
+			if entry.target, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("read symlink: %w", err)
+			}
+		case d.Type().IsRegular():
+			if entry.hash, err = hashFile(path); err != nil {
+				return err
+			}
+		default:
+			// Sockets, devices, etc. are not part of an actions cache
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// populateObjects creates a new objects directory and links (or copies, as a fallback)
+// the regular files of the template actions cache into it, named after their hash.
+func (s *actionsCacheStore) populateObjects() error {
+	if err := os.MkdirAll(s.objectsBasePath, 0o755); err != nil {
+		return fmt.Errorf("create objects base directory: %w", err)
+	}
+	objectsPath, err := os.MkdirTemp(s.objectsBasePath, "objects-")
+	if err != nil {
+		return fmt.Errorf("create objects directory: %w", err)
+	}
+	s.objectsPath = objectsPath
+	for _, entry := range s.entries {
+		if entry.hash == "" {
+			continue
+		}
+		src := filepath.Join(s.templatePath, entry.path)
+		dst := s.objectPath(entry.hash)
+		if _, err := os.Stat(dst); err == nil {
+			// Same content, already stored
+			continue
+		}
+		if err := os.Link(src, dst); err == nil || errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materialize creates the given entries in dst, using the content of the objects directory.
+func (s *actionsCacheStore) materialize(entries []actionsCacheEntry, dst string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return fmt.Errorf("create destination directory: %w", err)
+	}
+	for _, entry := range entries {
+		dstPath := filepath.Join(dst, entry.path)
+		switch {
+		case entry.mode.IsDir():
+			if err := os.MkdirAll(dstPath, entry.mode.Perm()|0o700); err != nil {
+				return fmt.Errorf("create directory: %w", err)
+			}
+		case entry.target != "":
+			if err := os.Symlink(entry.target, dstPath); err != nil && !errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("create symlink: %w", err)
+			}
+		default:
+			if err := linkObject(s.objectPath(entry.hash), dstPath, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// linkObject creates dst from the given object, preferring a reflink.
+// Immutable git objects fall back to a hardlink, everything else to a copy.
+func linkObject(object, dst string, entry actionsCacheEntry) error {
+	if _, err := os.Lstat(dst); err == nil {
+		// Reusing an existing per-runner cache: replace the file, so changes from previous runs are discarded
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("remove existing file: %w", err)
+		}
+	}
+	if err := reflinkFile(object, dst, entry.mode.Perm()); err == nil {
+		return nil
+	}
+	if isImmutableGitObject(entry.path) {
+		if err := os.Link(object, dst); err == nil {
+			return nil
+		}
+	}
+	if err := copyFile(object, dst); err != nil {
+		return err
+	}
+	return os.Chmod(dst, entry.mode.Perm())
+}
+
+// isImmutableGitObject returns true if the given path is a git object or pack file.
+// Git never modifies these files in place, so they can be safely hardlinked.
+func isImmutableGitObject(path string) bool {
+	return slices.Contains(strings.Split(filepath.ToSlash(filepath.Dir(path)), "/"), "objects")
+}
+
+// objectPath returns the path of the object with the given hash.
+func (s *actionsCacheStore) objectPath(hash string) string {
+	return filepath.Join(s.objectsPath, hash)
+}
+
+// hashFile returns the hex-encoded SHA-256 of the content of the given file.
+func hashFile(path string) (hash string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
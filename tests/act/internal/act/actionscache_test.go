@@ -0,0 +1,102 @@
+package act
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionsCacheStore(t *testing.T) {
+	base := t.TempDir()
+	template := filepath.Join(base, "template")
+	for fn, content := range map[string]string{
+		"actions-checkout/HEAD":                "ref: refs/heads/main\n",
+		"actions-checkout/objects/pack/a.pack": "pack content",
+		"actions-setup-go/HEAD":                "ref: refs/heads/main\n",
+		"actions-setup-go/objects/pack/b.pack": "other pack content",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(template, fn)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(template, fn), []byte(content), 0o644))
+	}
+	require.NoError(t, os.Symlink("HEAD", filepath.Join(template, "actions-checkout", "HEAD-link")))
+
+	s := newActionsCacheStore(template, base)
+	first := filepath.Join(base, "first")
+	releaseFirst, err := s.checkout(first)
+	require.NoError(t, err)
+	second := filepath.Join(base, "second")
+	releaseSecond, err := s.checkout(second)
+	require.NoError(t, err)
+
+	// Identical files are stored once
+	objectsPath := s.objectsPath
+	objects, err := os.ReadDir(objectsPath)
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+
+	for _, dst := range []string{first, second} {
+		content, err := os.ReadFile(filepath.Join(dst, "actions-setup-go", "objects", "pack", "b.pack"))
+		require.NoError(t, err)
+		require.Equal(t, "other pack content", string(content))
+		target, err := os.Readlink(filepath.Join(dst, "actions-checkout", "HEAD-link"))
+		require.NoError(t, err)
+		require.Equal(t, "HEAD", target)
+	}
+
+	// Changes to a per-runner cache don't affect the template
+	require.NoError(t, os.WriteFile(filepath.Join(first, "actions-checkout", "HEAD"), []byte("changed"), 0o644))
+	content, err := os.ReadFile(filepath.Join(template, "actions-checkout", "HEAD"))
+	require.NoError(t, err)
+	require.Equal(t, "ref: refs/heads/main\n", string(content))
+
+	// Per-runner caches are removed on release, and the objects when the last one is released
+	require.NoError(t, releaseFirst())
+	require.NoDirExists(t, first)
+	require.DirExists(t, objectsPath)
+	require.NoError(t, releaseSecond())
+	require.NoDirExists(t, second)
+	require.NoDirExists(t, objectsPath)
+	require.DirExists(t, template)
+
+	// The store can be used again after being fully released
+	release, err := s.checkout(first)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(first, "actions-checkout", "objects", "pack", "a.pack"))
+	require.NoError(t, release())
+}
+
+func TestActionsCacheStoreSharedBase(t *testing.T) {
+	base := t.TempDir()
+	template := filepath.Join(base, "template")
+	require.NoError(t, os.MkdirAll(filepath.Join(template, "actions-checkout"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(template, "actions-checkout", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+
+	// Stores of different processes share the same base directory
+	first := newActionsCacheStore(template, base)
+	second := newActionsCacheStore(template, base)
+	releaseFirst, err := first.checkout(filepath.Join(base, "first"))
+	require.NoError(t, err)
+	releaseSecond, err := second.checkout(filepath.Join(base, "second"))
+	require.NoError(t, err)
+	require.NotEqual(t, first.objectsPath, second.objectsPath)
+
+	// Releasing the last checkout of a store doesn't remove the objects of the other one
+	secondObjectsPath := second.objectsPath
+	require.NoError(t, releaseFirst())
+	require.DirExists(t, secondObjectsPath)
+	release, err := second.checkout(filepath.Join(base, "third"))
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(base, "third", "actions-checkout", "HEAD"))
+	require.NoError(t, release())
+	require.NoError(t, releaseSecond())
+	require.NoDirExists(t, secondObjectsPath)
+}
+
+func TestIsImmutableGitObject(t *testing.T) {
+	require.True(t, isImmutableGitObject("actions-checkout/objects/pack/pack-1.pack"))
+	require.True(t, isImmutableGitObject("actions-checkout/.git/objects/ab/cdef"))
+	require.False(t, isImmutableGitObject("actions-checkout/HEAD"))
+	require.False(t, isImmutableGitObject("actions-checkout/refs/heads/objects"))
+}
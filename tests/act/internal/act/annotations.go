@@ -0,0 +1,119 @@
+package act
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// AnnotationLevel represents the level of a GitHub Actions annotation.
+type AnnotationLevel string
+
+// Annotation levels
+const (
+	AnnotationLevelDebug   AnnotationLevel = "debug"
+	AnnotationLevelNotice  AnnotationLevel = "notice"
+	AnnotationLevelWarning AnnotationLevel = "warning"
+	AnnotationLevelError   AnnotationLevel = "error"
+)
+
+// Annotation represents a single GitHub Actions annotation.
+type Annotation struct {
+	// Level is the level of the annotation.
+	Level AnnotationLevel `json:"level"`
+
+	// Title is the optional title of the annotation.
+	Title string `json:"title,omitempty"`
+
+	// Message is the message of the annotation itself.
+	Message string `json:"message"`
+
+	// JobID is the ID of the job that raised the annotation.
+	JobID string `json:"job_id,omitempty"`
+
+	// StepID is the ID of the top-level step that raised the annotation.
+	StepID string `json:"step_id,omitempty"`
+
+	// Matrix is the matrix leg of the job that raised the annotation, if the job has a matrix.
+	Matrix Matrix `json:"matrix,omitempty"`
+
+	// File is the optional path of the file the annotation refers to.
+	File string `json:"file,omitempty"`
+
+	// Line is the optional (1-based) line of File the annotation refers to.
+	Line int `json:"line,omitempty"`
+
+	// EndLine is the optional end line of the range of File the annotation refers to.
+	EndLine int `json:"end_line,omitempty"`
+
+	// Col is the optional (1-based) column of Line the annotation refers to.
+	Col int `json:"col,omitempty"`
+
+	// EndColumn is the optional end column of the range of File the annotation refers to.
+	EndColumn int `json:"end_column,omitempty"`
+}
+
+// newAnnotation creates the Annotation for the given annotation command, raised by the given top-level step.
+// The location properties that are not valid numbers are ignored.
+func newAnnotation(data logLine, stepID string) Annotation {
+	atoi := func(property string) int {
+		v, _ := strconv.Atoi(data.KvPairs[property])
+		return v
+	}
+	annotation := Annotation{
+		Level:     AnnotationLevel(data.Command),
+		Title:     data.KvPairs["title"],
+		Message:   data.Arg,
+		JobID:     data.JobID,
+		StepID:    stepID,
+		File:      data.KvPairs["file"],
+		Line:      atoi("line"),
+		EndLine:   atoi("endLine"),
+		Col:       atoi("col"),
+		EndColumn: atoi("endColumn"),
+	}
+	// act reports an empty matrix for jobs without a matrix
+	if len(data.Matrix) > 0 {
+		annotation.Matrix = data.Matrix
+	}
+	return annotation
+}
+
+// Annotations is a list of GitHub Actions annotations.
+type Annotations []Annotation
+
+// Filter returns the annotations with the given level whose title or message match the given regexp.
+// An empty level matches all levels, and a nil regexp matches all annotations.
+func (a Annotations) Filter(level AnnotationLevel, re *regexp.Regexp) Annotations {
+	var filtered Annotations
+	for _, annotation := range a {
+		if level != "" && annotation.Level != level {
+			continue
+		}
+		if re != nil && !re.MatchString(annotation.Title) && !re.MatchString(annotation.Message) {
+			continue
+		}
+		filtered = append(filtered, annotation)
+	}
+	return filtered
+}
+
+// WithoutLocation returns a copy of the annotations without the job, matrix leg, step and file location they were raised at,
+// so they can be compared to annotations that only specify the level, title and message.
+func (a Annotations) WithoutLocation() Annotations {
+	stripped := make(Annotations, 0, len(a))
+	for _, annotation := range a {
+		stripped = append(stripped, Annotation{Level: annotation.Level, Title: annotation.Title, Message: annotation.Message})
+	}
+	return stripped
+}
+
+// AnnotationsFor returns the annotations raised by the given job, in order.
+func (r *RunResult) AnnotationsFor(jobID string) Annotations {
+	var annotations Annotations
+	for _, annotation := range r.Annotations {
+		if annotation.JobID == jobID {
+			annotations = append(annotations, annotation)
+		}
+	}
+	return annotations
+}
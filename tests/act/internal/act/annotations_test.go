@@ -0,0 +1,45 @@
+package act
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotations(t *testing.T) {
+	r := newRunResult()
+	r.Annotations = Annotations{
+		{Level: AnnotationLevelError, Title: "plugin-validator: Error: Archive contains more than one directory", Message: "Found 2 directories", JobID: "test-and-build", StepID: "validator"},
+		{Level: AnnotationLevelWarning, Message: "unused variable", JobID: "lint", StepID: "eslint", File: "src/module.ts", Line: 12},
+		{Level: AnnotationLevelError, Message: "missing semicolon", JobID: "lint", StepID: "eslint", File: "src/datasource.ts", Line: 3, Col: 10},
+	}
+
+	t.Run("for job", func(t *testing.T) {
+		lint := r.AnnotationsFor("lint")
+		require.Len(t, lint, 2)
+		require.Equal(t, "src/module.ts", lint[0].File)
+		require.Equal(t, "src/datasource.ts", lint[1].File)
+		require.Empty(t, r.AnnotationsFor("does-not-exist"))
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		errors := r.Annotations.Filter(AnnotationLevelError, nil)
+		require.Len(t, errors, 2)
+
+		// Matches the title as well as the message
+		validator := r.Annotations.Filter(AnnotationLevelError, regexp.MustCompile(`^plugin-validator:`))
+		require.Len(t, validator, 1)
+		require.Equal(t, "validator", validator[0].StepID)
+
+		semicolon := r.AnnotationsFor("lint").Filter("", regexp.MustCompile(`semicolon`))
+		require.Equal(t, Annotations{r.Annotations[2]}, semicolon)
+
+		require.Empty(t, r.Annotations.Filter(AnnotationLevelNotice, nil))
+	})
+
+	t.Run("without location", func(t *testing.T) {
+		require.Contains(t, r.Annotations.WithoutLocation(), Annotation{Level: AnnotationLevelWarning, Message: "unused variable"})
+		require.NotContains(t, r.Annotations, Annotation{Level: AnnotationLevelWarning, Message: "unused variable"})
+	})
+}
@@ -3,13 +3,25 @@ package act
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/afero/zipfs"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
 )
 
 // ArtifactFolder represents a folder containing artifacts uploaded during a workflow run
@@ -23,8 +35,11 @@ type ArtifactFolder struct {
 	rawFile *os.File
 }
 
-// Close closes the underlying ZIP file that is used by the ArtifactFolder.
+// Close closes the underlying ZIP file that is used by the ArtifactFolder, if any.
 func (a *ArtifactFolder) Close() error {
+	if a.rawFile == nil {
+		return nil
+	}
 	return a.rawFile.Close()
 }
 
@@ -59,20 +74,105 @@ func (a *ArtifactFolder) ReadFile(fn string) ([]byte, error) {
 	return afero.ReadFile(a.Fs, fn)
 }
 
+// ArtifactLayout is the way an artifact is stored by act's artifact server.
+type ArtifactLayout string
+
+const (
+	// ArtifactLayoutV3 is the layout of the artifacts uploaded by actions/upload-artifact v3 or older:
+	// each uploaded file is stored separately, under <run>/<name>/, gzipped files with a ".gz__" suffix.
+	ArtifactLayoutV3 ArtifactLayout = "v3"
+
+	// ArtifactLayoutV4 is the layout of the artifacts uploaded by actions/upload-artifact v4 or newer:
+	// all the uploaded files are stored in a single ZIP file, <run>/<name>/<name>.zip.
+	ArtifactLayoutV4 ArtifactLayout = "v4"
+)
+
+// v3GzipSuffix is the suffix act's artifact server adds to the files uploaded gzipped with the v3 protocol.
+const v3GzipSuffix = ".gz__"
+
+// DefaultArtifactRetentionDays is the retention of the artifacts uploaded without the retention-days input.
+const DefaultArtifactRetentionDays = 90
+
+// ArtifactFile is a file in an artifact.
+type ArtifactFile struct {
+	// Path is the path of the file in the artifact.
+	Path string
+
+	// Size is the uncompressed size of the file, in bytes.
+	Size int64
+
+	// Files are the files in the file, if it's a ZIP file (like the plugin ZIPs in the dist artifacts).
+	Files []ArtifactFile
+}
+
+// Artifact is an artifact uploaded during a workflow run, as stored by act's artifact server.
+type Artifact struct {
+	// Name is the name of the artifact.
+	Name string
+
+	// RunID is the ID of the workflow run that uploaded the artifact.
+	RunID string
+
+	// Layout is the way the artifact is stored, which depends on the version of actions/upload-artifact.
+	Layout ArtifactLayout
+
+	// Size is the size of the stored artifact, in bytes (the size of the ZIP file for ArtifactLayoutV4).
+	Size int64
+
+	// Files are the files in the artifact, sorted by path.
+	Files []ArtifactFile
+
+	// UploadedAt is the time the upload of the artifact finished.
+	UploadedAt time.Time
+
+	// RetentionDays is the retention-days input of the actions/upload-artifact step that uploaded the artifact,
+	// or DefaultArtifactRetentionDays if not set. It's only set if RetentionErr is nil.
+	RetentionDays int
+
+	// RetentionErr is the reason why the retention of the artifact couldn't be resolved.
+	// act's artifact server doesn't store the retention, so it's resolved statically from the workflow of the run.
+	// That's not possible when the retention-days input depends on an expression other than ${{ inputs.<name> }},
+	// when several steps may have uploaded the artifact with different retentions,
+	// or when the run ID is unknown because the workflow was not created via NewTestingWorkflow.
+	RetentionErr error
+}
+
+// ExpiresAt returns the time the artifact would expire on GitHub.
+// It returns RetentionErr if the retention of the artifact couldn't be resolved.
+func (a Artifact) ExpiresAt() (time.Time, error) {
+	if a.RetentionErr != nil {
+		return time.Time{}, a.RetentionErr
+	}
+	return a.UploadedAt.AddDate(0, 0, a.RetentionDays), nil
+}
+
+// FilePaths returns the paths of the files in the artifact, sorted.
+func (a Artifact) FilePaths() []string {
+	paths := make([]string, 0, len(a.Files))
+	for _, f := range a.Files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
 // ArtifactsStorage manages the storage of artifacts uploaded during workflow runs.
 // An ArtifactStorage can hold multiple artifacts from different workflow runs.
 // Each artifact is identified by a run ID and artifact name
-// and is stored as a ZIP file on the local filesystem.
+// and is stored on the local filesystem, with the layout of act's artifact server (see ArtifactLayout).
 type ArtifactsStorage struct {
 	// basePath is the base path where artifacts are stored.
 	// Each artifact is stored as a ZIP file, containing the uploaded files.
 	basePath string
+
+	// retentions holds the retention of the artifacts uploaded by the workflows run by the Runner.
+	retentions *artifactRetentions
 }
 
 // newArtifactsStorage creates a new ArtifactsStorage instance for the given Runner.
 func newArtifactsStorage(r *Runner) ArtifactsStorage {
 	return ArtifactsStorage{
-		basePath: "/tmp/act-artifacts/" + r.uuid.String() + "/",
+		basePath:   "/tmp/act-artifacts/" + r.uuid.String() + "/",
+		retentions: &artifactRetentions{runs: map[string][]artifactRetention{}},
 	}
 }
 
@@ -81,10 +181,34 @@ func (a ArtifactsStorage) runFolder(runID string) string {
 	return filepath.Join(a.basePath, runID)
 }
 
+// artifactLayout returns the layout of the artifact with the given name in the given run.
+func (a ArtifactsStorage) artifactLayout(runID string, artifactName string) (ArtifactLayout, error) {
+	dir := filepath.Join(a.runFolder(runID), artifactName)
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	if finfo, err := os.Stat(filepath.Join(dir, artifactName+".zip")); err == nil && finfo.Mode().IsRegular() {
+		return ArtifactLayoutV4, nil
+	}
+	return ArtifactLayoutV3, nil
+}
+
 // GetFolder retrieves the artifact folder for the given run ID and artifact name.
+// Both ArtifactLayoutV3 and ArtifactLayoutV4 artifacts are supported.
 // The caller should call Close() on the returned ArtifactFolder when done using it.
 func (a ArtifactsStorage) GetFolder(runID string, artifactName string) (*ArtifactFolder, error) {
+	layout, err := a.artifactLayout(runID, artifactName)
+	if err != nil {
+		return nil, err
+	}
 	bd := a.runFolder(runID)
+	if layout == ArtifactLayoutV3 {
+		memFs, err := readV3Artifact(filepath.Join(bd, artifactName))
+		if err != nil {
+			return nil, fmt.Errorf("read artifact %q: %w", artifactName, err)
+		}
+		return &ArtifactFolder{Fs: memFs}, nil
+	}
 	artifactFn := filepath.Join(bd, artifactName, artifactName+".zip")
 	finfo, err := os.Stat(artifactFn)
 	if err != nil {
@@ -96,7 +220,388 @@ func (a ArtifactsStorage) GetFolder(runID string, artifactName string) (*Artifac
 	}
 	zf, err := zip.NewReader(rawF, finfo.Size())
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, rawF.Close())
 	}
 	return &ArtifactFolder{Fs: zipfs.New(zf), rawFile: rawF}, nil
 }
+
+// List returns all the artifacts uploaded by the workflow run with the given ID, sorted by name.
+// It returns an empty list if the run didn't upload any artifacts.
+func (a ArtifactsStorage) List(runID string) ([]Artifact, error) {
+	entries, err := os.ReadDir(a.runFolder(runID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Artifact{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read run folder: %w", err)
+	}
+	artifacts := make([]Artifact, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		artifact, err := a.Get(runID, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// Get returns the artifact with the given name uploaded by the workflow run with the given ID.
+func (a ArtifactsStorage) Get(runID string, artifactName string) (Artifact, error) {
+	layout, err := a.artifactLayout(runID, artifactName)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("get artifact %q: %w", artifactName, err)
+	}
+	artifact := Artifact{
+		Name:   artifactName,
+		RunID:  runID,
+		Layout: layout,
+	}
+	artifact.RetentionDays, artifact.RetentionErr = a.retentions.get(runID, artifactName)
+	dir := filepath.Join(a.runFolder(runID), artifactName)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		finfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		artifact.Size += finfo.Size()
+		if finfo.ModTime().After(artifact.UploadedAt) {
+			artifact.UploadedAt = finfo.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return Artifact{}, fmt.Errorf("stat artifact %q: %w", artifactName, err)
+	}
+	if layout == ArtifactLayoutV4 {
+		artifact.Files, err = listZIPFile(filepath.Join(dir, artifactName+".zip"))
+	} else {
+		artifact.Files, err = listV3Files(dir)
+	}
+	if err != nil {
+		return Artifact{}, fmt.Errorf("list artifact %q files: %w", artifactName, err)
+	}
+	return artifact, nil
+}
+
+// listZIPFile returns the files in the ZIP file with the given path, sorted by path.
+// The files of nested ZIP files are listed as well.
+func listZIPFile(path string) (files []ArtifactFile, err error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	defer func() {
+		if closeErr := zr.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	return listZIPFiles(&zr.Reader)
+}
+
+// listZIPFiles returns the files in the given ZIP, sorted by path.
+// The files of nested ZIP files are listed as well.
+func listZIPFiles(zr *zip.Reader) ([]ArtifactFile, error) {
+	files := []ArtifactFile{}
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		f := ArtifactFile{Path: strings.TrimPrefix(zf.Name, "/"), Size: int64(zf.UncompressedSize64)}
+		if strings.HasSuffix(zf.Name, ".zip") {
+			content, err := readZIPEntry(zf)
+			if err != nil {
+				return nil, err
+			}
+			if f.Files, err = listNestedZIP(f.Path, content); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, f)
+	}
+	sortArtifactFiles(files)
+	return files, nil
+}
+
+// readZIPEntry returns the uncompressed content of the given ZIP entry.
+func readZIPEntry(zf *zip.File) (content []byte, err error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", zf.Name, err)
+	}
+	defer func() {
+		if closeErr := rc.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	return io.ReadAll(rc)
+}
+
+// listNestedZIP returns the files in the nested ZIP file with the given path and content, sorted by path.
+func listNestedZIP(path string, content []byte) ([]ArtifactFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("new zip reader %q: %w", path, err)
+	}
+	return listZIPFiles(zr)
+}
+
+// listV3Files returns the files of the ArtifactLayoutV3 artifact in the given directory, sorted by path.
+// The files of nested ZIP files are listed as well.
+func listV3Files(dir string) ([]ArtifactFile, error) {
+	memFs, err := readV3Artifact(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []ArtifactFile{}
+	err = afero.Walk(memFs, "/", func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		f := ArtifactFile{Path: strings.TrimPrefix(filepath.ToSlash(path), "/"), Size: info.Size()}
+		if strings.HasSuffix(path, ".zip") {
+			content, err := afero.ReadFile(memFs, path)
+			if err != nil {
+				return err
+			}
+			if f.Files, err = listNestedZIP(f.Path, content); err != nil {
+				return err
+			}
+		}
+		files = append(files, f)
+		return nil
+	})
+	sortArtifactFiles(files)
+	return files, err
+}
+
+// sortArtifactFiles sorts the given files by path.
+func sortArtifactFiles(files []ArtifactFile) {
+	slices.SortFunc(files, func(a, b ArtifactFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+}
+
+// readV3Artifact reads the files of the ArtifactLayoutV3 artifact in the given directory into memory,
+// decompressing the gzipped ones.
+func readV3Artifact(dir string) (afero.Fs, error) {
+	memFs := afero.NewMemMapFs()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("get relative path: %w", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		if strings.HasSuffix(relPath, v3GzipSuffix) {
+			relPath = strings.TrimSuffix(relPath, v3GzipSuffix)
+			gr, err := gzip.NewReader(bytes.NewReader(content))
+			if err != nil {
+				return fmt.Errorf("gzip reader %q: %w", relPath, err)
+			}
+			if content, err = io.ReadAll(gr); err != nil {
+				return fmt.Errorf("gunzip %q: %w", relPath, err)
+			}
+		}
+		return afero.WriteFile(memFs, "/"+filepath.ToSlash(relPath), content, 0o644)
+	})
+	// Resolve the relative paths from the root, like zipfs does
+	return afero.NewBasePathFs(memFs, "/"), err
+}
+
+// artifactRetentions is the retention of the artifacts uploaded by the workflow runs, by run ID.
+type artifactRetentions struct {
+	mu   sync.Mutex
+	runs map[string][]artifactRetention
+}
+
+// artifactRetention is the retention of the artifacts uploaded by an actions/upload-artifact step.
+type artifactRetention struct {
+	// name is the name of the uploaded artifacts, with the ${{ inputs.<name> }} expressions resolved.
+	name string
+
+	// pattern matches the names of the uploaded artifacts, if name contains other expressions
+	// (e.g.: ${{ matrix.os }}), which match any value.
+	pattern *regexp.Regexp
+
+	// days is the retention in days, if err is nil.
+	days int
+
+	// err is the reason why the retention can't be resolved.
+	err error
+}
+
+// matches returns true if the step may have uploaded the artifact with the given name.
+func (r artifactRetention) matches(artifactName string) bool {
+	if r.pattern != nil {
+		return r.pattern.MatchString(artifactName)
+	}
+	return r.name == artifactName
+}
+
+// get returns the retention of the artifact with the given name uploaded by the run with the given ID.
+// The steps uploading an artifact with exactly that name take precedence over the ones whose name
+// contains expressions. An error is returned if no step matches, or if the matching steps have
+// different or unresolved retentions.
+func (r *artifactRetentions) get(runID string, artifactName string) (int, error) {
+	if r == nil {
+		return 0, errors.New("artifact retentions are not recorded")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	steps, ok := r.runs[runID]
+	if !ok {
+		return 0, fmt.Errorf("unknown workflow for run %q: the run ID is only known for the workflows created via NewTestingWorkflow", runID)
+	}
+	var matches []artifactRetention
+	for _, exact := range []bool{true, false} {
+		for _, step := range steps {
+			if (step.pattern == nil) == exact && step.matches(artifactName) {
+				matches = append(matches, step)
+			}
+		}
+		if len(matches) > 0 {
+			break
+		}
+	}
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no actions/upload-artifact step uploads an artifact named %q", artifactName)
+	}
+	for _, m := range matches {
+		if m.err != nil {
+			return 0, fmt.Errorf("artifact %q: %w", artifactName, m.err)
+		}
+		if m.days != matches[0].days {
+			return 0, fmt.Errorf("artifact %q: ambiguous retention: uploaded by steps with %d and %d retention days", artifactName, matches[0].days, m.days)
+		}
+	}
+	return matches[0].days, nil
+}
+
+// record records the retention of the artifacts uploaded by the steps of the run with the given ID.
+func (r *artifactRetentions) record(runID string, steps []artifactRetention) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[runID] = steps
+}
+
+// reset forgets the retention of the artifacts of all the runs.
+func (r *artifactRetentions) reset() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = map[string][]artifactRetention{}
+}
+
+// workflowArtifactRetentions returns the retention of the artifacts uploaded by the actions/upload-artifact steps
+// of the given workflow and its children. See recordArtifactRetentions.
+func workflowArtifactRetentions(wf workflow.Workflow) []artifactRetention {
+	var steps []artifactRetention
+	recordArtifactRetentions(wf, nil, &steps)
+	return steps
+}
+
+// inputExpressionRegex matches the ${{ inputs.<name> }} expressions.
+var inputExpressionRegex = regexp.MustCompile(`\$\{\{\s*inputs\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+// expressionRegex matches the ${{ <expression> }} expressions.
+var expressionRegex = regexp.MustCompile(`\$\{\{.*?\}\}`)
+
+// interpolateInputs replaces the ${{ inputs.<name> }} expressions in the given value with the given inputs.
+// It returns false if the value contains other expressions, or unknown inputs, which are left as-is.
+func interpolateInputs(value any, inputs map[string]any) (string, bool) {
+	ok := true
+	s := inputExpressionRegex.ReplaceAllStringFunc(fmt.Sprint(value), func(m string) string {
+		v, found := inputs[inputExpressionRegex.FindStringSubmatch(m)[1]]
+		if !found {
+			ok = false
+			return m
+		}
+		return fmt.Sprint(v)
+	})
+	return s, ok && !strings.Contains(s, "${{")
+}
+
+// expressionPattern returns a regexp matching the values of the given string, where each expression can be any value.
+func expressionPattern(s string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range expressionRegex.FindAllStringIndex(s, -1) {
+		b.WriteString(regexp.QuoteMeta(s[last:loc[0]]))
+		b.WriteString(".*")
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(s[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// recordArtifactRetentions appends the retention of the artifacts uploaded by the actions/upload-artifact steps
+// of the given workflow (with the given workflow_call inputs) and its children to steps.
+// Only the ${{ inputs.<name> }} expressions are resolved: the other expressions in the artifact names match any value,
+// and the steps with other expressions in retention-days have an unresolved retention.
+func recordArtifactRetentions(wf workflow.Workflow, inputs map[string]any, steps *[]artifactRetention) {
+	jobs := wf.Jobs()
+	for _, id := range slices.Sorted(maps.Keys(jobs)) {
+		job := jobs[id]
+		if child := calledChild(wf, job); child != nil {
+			childInputs := map[string]any{}
+			for name, input := range child.On.WorkflowCall.Inputs {
+				if input.Default != nil {
+					childInputs[name] = input.Default
+				}
+			}
+			for name, value := range job.With {
+				if v, ok := interpolateInputs(value, inputs); ok {
+					childInputs[name] = v
+				} else {
+					delete(childInputs, name)
+				}
+			}
+			recordArtifactRetentions(child, childInputs, steps)
+			continue
+		}
+		for _, step := range job.Steps {
+			if !strings.Contains(step.Uses, "actions/upload-artifact@") {
+				continue
+			}
+			retention := artifactRetention{name: "artifact", days: DefaultArtifactRetentionDays}
+			if v, set := step.With["name"]; set {
+				var ok bool
+				if retention.name, ok = interpolateInputs(v, inputs); !ok {
+					retention.pattern = expressionPattern(retention.name)
+				}
+			}
+			if v, set := step.With["retention-days"]; set {
+				days, ok := interpolateInputs(v, inputs)
+				n, err := strconv.Atoi(days)
+				switch {
+				case !ok:
+					retention.err = fmt.Errorf("retention-days %q of job %q can't be resolved statically", days, id)
+				case err != nil:
+					retention.err = fmt.Errorf("invalid retention-days %q of job %q: %w", days, id, err)
+				case n > 0:
+					retention.days = n
+				}
+			}
+			*steps = append(*steps, retention)
+		}
+	}
+}
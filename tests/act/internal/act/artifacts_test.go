@@ -0,0 +1,189 @@
+package act
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow/ci"
+	"github.com/stretchr/testify/require"
+)
+
+// zipContent returns a ZIP file with the given files.
+func zipContent(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for fn, content := range files {
+		w, err := zw.Create(fn)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// writeArtifactFile writes a file of an artifact in the given ArtifactsStorage.
+func writeArtifactFile(t *testing.T, a ArtifactsStorage, runID string, path string, content []byte) {
+	t.Helper()
+	fn := filepath.Join(a.runFolder(runID), path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0o755))
+	require.NoError(t, os.WriteFile(fn, content, 0o644))
+}
+
+func TestArtifactsStorage(t *testing.T) {
+	a := ArtifactsStorage{basePath: t.TempDir()}
+
+	// v4: a single ZIP, containing a nested ZIP
+	pluginZIP := zipContent(t, map[string][]byte{"plugin/plugin.json": []byte("{}"), "plugin/module.js": []byte("console.log()")})
+	writeArtifactFile(t, a, "1", filepath.Join("dist-artifacts", "dist-artifacts.zip"), zipContent(t, map[string][]byte{
+		"plugin.zip":     pluginZIP,
+		"plugin.zip.md5": []byte("abc"),
+	}))
+
+	// v3: one file per uploaded file, gzipped ones with a suffix
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	writeArtifactFile(t, a, "1", filepath.Join("report", "index.html"), []byte("<html></html>"))
+	writeArtifactFile(t, a, "1", filepath.Join("report", "data", "log.txt"+v3GzipSuffix), gz.Bytes())
+
+	t.Run("list", func(t *testing.T) {
+		artifacts, err := a.List("1")
+		require.NoError(t, err)
+		require.Len(t, artifacts, 2)
+
+		dist := artifacts[0]
+		require.Equal(t, "dist-artifacts", dist.Name)
+		require.Equal(t, "1", dist.RunID)
+		require.Equal(t, ArtifactLayoutV4, dist.Layout)
+		require.Equal(t, []string{"plugin.zip", "plugin.zip.md5"}, dist.FilePaths())
+		require.Equal(t, int64(len(pluginZIP)), dist.Files[0].Size)
+		require.Equal(t, []ArtifactFile{
+			{Path: "plugin/module.js", Size: int64(len("console.log()"))},
+			{Path: "plugin/plugin.json", Size: 2},
+		}, dist.Files[0].Files)
+		require.NotZero(t, dist.Size)
+		require.False(t, dist.UploadedAt.IsZero())
+		require.Error(t, dist.RetentionErr, "retention should be unknown")
+		require.Zero(t, dist.RetentionDays)
+		_, err = dist.ExpiresAt()
+		require.ErrorIs(t, err, dist.RetentionErr)
+
+		report := artifacts[1]
+		require.Equal(t, "report", report.Name)
+		require.Equal(t, ArtifactLayoutV3, report.Layout)
+		require.Equal(t, []ArtifactFile{
+			{Path: "data/log.txt", Size: int64(len("hello world"))},
+			{Path: "index.html", Size: int64(len("<html></html>"))},
+		}, report.Files)
+	})
+
+	t.Run("get folder", func(t *testing.T) {
+		report, err := a.GetFolder("1", "report")
+		require.NoError(t, err)
+		content, err := report.ReadFile("data/log.txt")
+		require.NoError(t, err)
+		require.Equal(t, "hello world", string(content))
+		require.NoError(t, report.Close())
+	})
+
+	t.Run("no artifacts", func(t *testing.T) {
+		artifacts, err := a.List("2")
+		require.NoError(t, err)
+		require.Empty(t, artifacts)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := a.Get("1", "does-not-exist")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestArtifactRetentions(t *testing.T) {
+	t.Run("ci workflow", func(t *testing.T) {
+		// The retentions are recorded for the run ID reported by the testing workflow
+		r := newTestRunner(t, &ScriptedExecutor{Output: []string{
+			jsonLogLine(t, logLine{JobID: getWorkflowRunIDJobID, StepID: []string{"run-id"}, Command: "set-output", Name: "run-id", Arg: "1"}),
+		}})
+		wf, err := ci.NewWorkflow(ci.WithWorkflowInputs(ci.WorkflowInputs{
+			DistArtifactsPrefix: workflow.Input("simple-frontend-"),
+		}))
+		require.NoError(t, err)
+		_, err = r.Run(wf, NewPushEventPayload("main"))
+		require.NoError(t, err)
+
+		writeArtifactFile(t, r.ArtifactsStorage, "1", filepath.Join("simple-frontend-dist-artifacts", "simple-frontend-dist-artifacts.zip"), zipContent(t, nil))
+		artifact, err := r.ArtifactsStorage.Get("1", "simple-frontend-dist-artifacts")
+		require.NoError(t, err)
+		require.NoError(t, artifact.RetentionErr)
+		require.Equal(t, 10, artifact.RetentionDays, "retention should be the default of the ci workflow input")
+		expiresAt, err := artifact.ExpiresAt()
+		require.NoError(t, err)
+		require.Equal(t, artifact.UploadedAt.AddDate(0, 0, 10), expiresAt)
+
+		writeArtifactFile(t, r.ArtifactsStorage, "2", filepath.Join("simple-frontend-dist-artifacts", "simple-frontend-dist-artifacts.zip"), zipContent(t, nil))
+		artifact, err = r.ArtifactsStorage.Get("2", "simple-frontend-dist-artifacts")
+		require.NoError(t, err)
+		require.ErrorContains(t, artifact.RetentionErr, "unknown workflow for run \"2\"", "retention should be unknown for other runs")
+		require.Zero(t, artifact.RetentionDays)
+	})
+
+	t.Run("inputs", func(t *testing.T) {
+		child := workflow.NewTestingWorkflow("child", workflow.BaseWorkflow{
+			On: workflow.On{WorkflowCall: workflow.OnWorkflowCall{Inputs: map[string]workflow.WorkflowCallInput{
+				"prefix":    {Type: "string", Default: ""},
+				"retention": {Type: "number", Default: 10},
+			}}},
+			Jobs: map[string]*workflow.Job{
+				"upload": {Steps: workflow.Steps{
+					{Uses: "actions/upload-artifact@v4", With: map[string]any{"name": "${{ inputs.prefix }}dist", "retention-days": "${{ inputs.retention }}"}},
+					{Uses: "actions/upload-artifact@v4", With: map[string]any{"name": "default-retention"}},
+					{Uses: "actions/upload-artifact@v4"},
+					{Uses: "actions/upload-artifact@v4", With: map[string]any{"name": "dynamic-retention", "retention-days": "${{ github.run_attempt }}"}},
+					{Uses: "actions/upload-artifact@v4", With: map[string]any{"name": "invalid-retention", "retention-days": "ten"}},
+				}},
+				"matrix": {Steps: workflow.Steps{
+					{Uses: "actions/upload-artifact@v4", With: map[string]any{"name": "report-${{ matrix.os }}", "retention-days": 5}},
+					{Uses: "actions/upload-artifact@v4", With: map[string]any{"name": "${{ matrix.os }}-dist", "retention-days": 1}},
+				}},
+			},
+		})
+		parent := workflow.NewTestingWorkflow("parent", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+			"call": {Uses: "./.github/workflows/" + child.FileName(), With: map[string]any{"prefix": "my-", "retention": 3}},
+		}})
+		parent.AddChild("child", child)
+
+		retentions := &artifactRetentions{runs: map[string][]artifactRetention{}}
+		retentions.record("1", workflowArtifactRetentions(parent))
+		for _, tc := range []struct {
+			artifact string
+			days     int
+			err      string
+		}{
+			{artifact: "my-dist", days: 3},
+			{artifact: "default-retention", days: DefaultArtifactRetentionDays},
+			{artifact: "artifact", days: DefaultArtifactRetentionDays},
+			{artifact: "report-linux", days: 5},
+			{artifact: "dynamic-retention", err: `retention-days "${{ github.run_attempt }}" of job "upload" can't be resolved statically`},
+			{artifact: "invalid-retention", err: `invalid retention-days "ten" of job "upload"`},
+			{artifact: "report-linux-dist", err: "ambiguous retention"},
+			{artifact: "other", err: `no actions/upload-artifact step uploads an artifact named "other"`},
+		} {
+			days, err := retentions.get("1", tc.artifact)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err, tc.artifact)
+				continue
+			}
+			require.NoError(t, err, tc.artifact)
+			require.Equal(t, tc.days, days, tc.artifact)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package act
+
+import (
+	"os"
+)
+
+// WithKeepOnFailure makes the Runner keep the state it created (temporary workflow files, artifacts,
+// mocked GCS files and actions cache) when the test fails, so it can be inspected for debugging.
+// By default, all the state is removed when the test ends.
+func WithKeepOnFailure(keep bool) RunnerOption {
+	return func(r *Runner) {
+		r.KeepOnFailure = keep
+	}
+}
+
+// onCleanup registers a t.Cleanup hook that calls fn to remove the given state created by the Runner.
+// If the test failed and KeepOnFailure is set, fn is not called and the kept state is logged instead.
+// Cleanup errors are logged, but they don't fail the test.
+func (r *Runner) onCleanup(what string, fn func() error) {
+	r.t.Cleanup(func() {
+		if r.KeepOnFailure && r.t.Failed() {
+			r.t.Logf("%s: test failed, keeping %s", r.name, what)
+			return
+		}
+		if err := fn(); err != nil {
+			r.t.Logf("%s: cleanup %s: %v", r.name, what, err)
+		}
+	})
+}
+
+// removeOnCleanup registers a t.Cleanup hook that removes the given path (recursively). See onCleanup.
+func (r *Runner) removeOnCleanup(path string) {
+	r.onCleanup(path, func() error {
+		return os.RemoveAll(path)
+	})
+}
@@ -0,0 +1,100 @@
+package act
+
+import "strings"
+
+// ignoredCommand is the command act reports for the workflow commands printed inside a stop-commands block,
+// which it doesn't process (e.g.: "⚙  ::set-output name=foo::bar").
+const ignoredCommand = "ignored"
+
+// WorkflowCommand is a GitHub Actions workflow command intercepted in the act output.
+// Commands written to the GITHUB_ENV, GITHUB_PATH and GITHUB_OUTPUT files are reported by act
+// as the equivalent set-env, add-path and set-output commands (act doesn't report the GITHUB_STATE file).
+// Commands printed inside a stop-commands block are not processed by act, so they are not recorded.
+type WorkflowCommand struct {
+	// JobID is the ID of the job that issued the command.
+	JobID string
+
+	// StepID is the ID of the step that issued the command.
+	// For composite actions, it contains the path of step IDs, starting with the top-level step.
+	StepID []string
+
+	// Command is the name of the command (e.g.: "set-env", "add-mask", "group").
+	Command string
+
+	// Name is the value of the "name" property of the command, if any (e.g.: for set-env and save-state).
+	Name string
+
+	// Value is the value of the command (e.g.: the value of the environment variable, or the title of the group).
+	// Secret values are masked, like in the logs: the actual values are in the Outputs, Env, State and Masks of the RunResult.
+	Value string
+}
+
+// LogGroup is a group of log lines, delimited by the group and endgroup workflow commands.
+type LogGroup struct {
+	// JobID is the ID of the job that opened the group.
+	JobID string
+
+	// Matrix is the matrix of the job leg that opened the group, if the job is a matrix job.
+	Matrix Matrix
+
+	// StepID is the ID of the top-level step that opened the group.
+	StepID string
+
+	// Title is the title of the group.
+	Title string
+
+	// Lines contains the log lines printed inside the group.
+	Lines []string
+}
+
+// jobLeg identifies a job, or a single leg of a matrix job, in the act log lines.
+// act reports the jobs by ID only, so same-ID jobs of different workflows (e.g.: reusable workflows) can't be told apart.
+type jobLeg struct {
+	jobID string
+
+	// matrixKey is the Key of the Matrix of the leg, or an empty string if the job is not a matrix job.
+	matrixKey string
+}
+
+// newJobLeg returns the jobLeg that produced the given log line.
+func newJobLeg(data logLine) jobLeg {
+	return jobLeg{jobID: data.JobID, matrixKey: data.Matrix.Key()}
+}
+
+// commandsState keeps the state of the workflow commands that span multiple log lines, per job leg.
+type commandsState struct {
+	// openGroups contains the log group currently open, per job leg.
+	// Matrix legs run in parallel, so their log lines are interleaved.
+	openGroups map[jobLeg]*LogGroup
+
+	// masker masks the secret values in the act output.
+	// It is fed with the values masked via add-mask, in addition to the Runner's secrets.
+	masker *secretMasker
+}
+
+// newCommandsState creates a new empty commandsState.
+func newCommandsState() *commandsState {
+	return &commandsState{
+		openGroups: map[jobLeg]*LogGroup{},
+		masker:     newSecretMasker(),
+	}
+}
+
+// stepPathSeparator separates the step IDs in the path of a step nested in composite actions.
+// Step IDs can only contain alphanumeric characters, "-" and "_", so it can't appear in a step ID.
+const stepPathSeparator = "/"
+
+// StepPath returns the path of a step nested in composite actions, from the IDs of the top-level step
+// and of the nested steps (e.g.: StepPath("setup", "vars") is "setup/vars").
+// It can be used to look up the outputs of nested steps.
+func StepPath(stepIDs ...string) string {
+	return strings.Join(stepIDs, stepPathSeparator)
+}
+
+// topLevelStepID returns the ID of the top-level step of the given log line, or an empty string if the line has no step.
+func topLevelStepID(data logLine) string {
+	if len(data.StepID) == 0 {
+		return ""
+	}
+	return data.StepID[0]
+}
@@ -0,0 +1,214 @@
+package act
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Executor executes act with the given CLI arguments and environment variables.
+// The Runner uses an Executor to run act, so that the act CLI can be replaced
+// with a fake implementation (see ScriptedExecutor) to test the Runner without Docker.
+type Executor interface {
+	// Execute starts act with the given CLI arguments and environment variables ("KEY=value").
+	// When ctx is done, the execution must be interrupted.
+	Execute(ctx context.Context, args []string, env []string) (Execution, error)
+}
+
+// Execution is a running act execution started by an Executor.
+type Execution interface {
+	// Output returns the merged stdout and stderr stream of act (JSON log lines).
+	// The stream is closed (EOF) when act exits.
+	Output() io.Reader
+
+	// Wait waits for act to exit and returns its exit code.
+	// It must be called after Output has been fully consumed.
+	// A non-zero exit code is not an error: the error is only returned if act could not run or exit properly.
+	Wait() (exitCode int, err error)
+}
+
+// ActCLIExecutor is an Executor that runs the act CLI in a shell.
+// When the context is done, the whole act process tree is killed.
+type ActCLIExecutor struct{}
+
+// NewActCLIExecutor creates a new ActCLIExecutor.
+// It returns an error if the act executable is not available in PATH.
+func NewActCLIExecutor() (*ActCLIExecutor, error) {
+	if !checkExecutable("act") {
+		return nil, errors.New(`"act" executable not found`)
+	}
+	return &ActCLIExecutor{}, nil
+}
+
+// Execute runs act with the given arguments and environment variables.
+func (e *ActCLIExecutor) Execute(ctx context.Context, args []string, env []string) (Execution, error) {
+	actCmd := shellCommand("act", args...)
+
+	// Merge stdout and stderr into a single pipe so both are grouped in GHA logs
+	mergedR, mergedW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create act output pipe: %w", err)
+	}
+
+	// Use a shell otherwise git will not be able to clone anything,
+	// not even publis repositories like actions/checkout for some reason.
+	cmd := exec.CommandContext(ctx, "sh", "-c", actCmd)
+	cmd.Env = env
+	cmd.Stdout = mergedW
+	cmd.Stderr = mergedW
+	// Run act in its own process group, so the whole process tree can be killed on cancellation
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process)
+	}
+
+	// Run act in the background
+	if err := cmd.Start(); err != nil {
+		_ = mergedR.Close()
+		_ = mergedW.Close()
+		return nil, fmt.Errorf("start act: %w", err)
+	}
+	// The act process holds its own copy of the write end of the pipe.
+	// Close ours, so the reader gets EOF as soon as act exits.
+	_ = mergedW.Close()
+
+	// If act has been killed, stop reading its output after a grace period,
+	// in case some orphaned process is still holding the pipe open.
+	stopAfterFunc := context.AfterFunc(ctx, func() {
+		time.AfterFunc(killGracePeriod, func() { _ = mergedR.Close() })
+	})
+	return &actCLIExecution{cmd: cmd, output: mergedR, stopAfterFunc: stopAfterFunc}, nil
+}
+
+// shellCommand returns the shell command line running the given command with the given arguments.
+// Every argument is quoted, so it's passed as-is to the command, whatever characters it contains.
+func shellCommand(name string, args ...string) string {
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, name)
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes the given string for a POSIX shell, unless it only contains characters that are never special.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+:,./@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// actCLIExecution is the Execution returned by ActCLIExecutor.
+type actCLIExecution struct {
+	cmd           *exec.Cmd
+	output        *os.File
+	stopAfterFunc func() bool
+}
+
+// Output returns the merged stdout and stderr stream of the act process.
+func (e *actCLIExecution) Output() io.Reader {
+	return e.output
+}
+
+// Wait waits for the act process to exit and returns its exit code.
+func (e *actCLIExecution) Wait() (int, error) {
+	defer e.stopAfterFunc()
+	defer func() { _ = e.output.Close() }()
+	if err := e.cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+// ScriptedExecutor is a fake Executor that writes a scripted act output instead of running act.
+// It can be used to test the Runner (and its act output processing) quickly, offline and without Docker.
+// It records the arguments of each execution for later assertions.
+//
+// ScriptedExecutor and its methods are safe for concurrent use.
+type ScriptedExecutor struct {
+	// Output contains the lines written to the output stream of each execution.
+	// They are usually act JSON log lines, but plain text lines are also allowed.
+	Output []string
+
+	// ExitCode is the exit code returned by each execution.
+	ExitCode int
+
+	// Block makes each execution hang after writing the output, until its context is done.
+	// This can be used to simulate a stuck act run.
+	Block bool
+
+	calls [][]string
+	mux   sync.Mutex
+}
+
+// Execute writes the scripted output and returns the scripted exit code.
+func (e *ScriptedExecutor) Execute(ctx context.Context, args []string, _ []string) (Execution, error) {
+	e.mux.Lock()
+	e.calls = append(e.calls, args)
+	e.mux.Unlock()
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, line := range e.Output {
+			if _, err := io.WriteString(pw, line+"\n"); err != nil {
+				return
+			}
+		}
+		if e.Block {
+			<-ctx.Done()
+		}
+		_ = pw.Close()
+	}()
+	return &scriptedExecution{ctx: ctx, output: pr, done: done, exitCode: e.ExitCode}, nil
+}
+
+// Calls returns the CLI arguments of all the executions so far.
+func (e *ScriptedExecutor) Calls() [][]string {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	calls := make([][]string, len(e.calls))
+	copy(calls, e.calls)
+	return calls
+}
+
+// scriptedExecution is the Execution returned by ScriptedExecutor.
+type scriptedExecution struct {
+	ctx      context.Context
+	output   io.Reader
+	done     chan struct{}
+	exitCode int
+}
+
+// Output returns the scripted output stream.
+func (e *scriptedExecution) Output() io.Reader {
+	return e.output
+}
+
+// Wait returns the scripted exit code, or -1 if the execution was interrupted.
+func (e *scriptedExecution) Wait() (int, error) {
+	<-e.done
+	if e.ctx.Err() != nil {
+		return -1, nil
+	}
+	return e.exitCode, nil
+}
+
+// Static checks
+
+var (
+	_ Executor = &ActCLIExecutor{}
+	_ Executor = &ScriptedExecutor{}
+)
@@ -0,0 +1,24 @@
+package act
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellCommand(t *testing.T) {
+	args := []string{
+		"push",
+		"--secret-file", "/tmp/TestContext/push_(testing)/001/secrets.env",
+		"--container-options", "-v /tmp/mock data:/mockdata --add-host=host.docker.internal:172.17.0.1",
+		"--secret", `GITHUB_TOKEN=it's a "secret" $HOME; exit 1`,
+		"",
+		"--artifact-server-path=/tmp/act-artifacts/1234/",
+	}
+	out, err := exec.Command("sh", "-c", shellCommand("printf", append([]string{`%s\n`}, args...)...)).Output()
+	require.NoError(t, err)
+	require.Equal(t, args, strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"), "arguments should be passed as-is")
+	require.Equal(t, "act push --rm", shellCommand("act", "push", "--rm"), "safe arguments should not be quoted")
+}
@@ -0,0 +1,170 @@
+package act
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+)
+
+// InfraError describes a failure of act because of the infrastructure
+// (Docker, network, git clones, ports, disk space), rather than because of the workflow itself.
+// It's reported in RunResult.InfraFailure, and returned by Runner.Run when all the attempts
+// of a RetryPolicy failed because of the infrastructure.
+type InfraError struct {
+	// Reason is a short description of the kind of infrastructure failure (e.g.: "docker image pull").
+	Reason string
+
+	// Line is the (masked) log line that matched the infrastructure failure pattern.
+	Line string
+
+	// ExitCode is act's exit code.
+	ExitCode int
+}
+
+// Error returns the error message.
+func (e *InfraError) Error() string {
+	return fmt.Sprintf("act infrastructure failure (%s, exit code %d): %s", e.Reason, e.ExitCode, e.Line)
+}
+
+// infraFailurePatterns are the patterns of act and Docker log lines that indicate an infrastructure failure.
+// They are only matched against plain-text lines and the messages of act's own log lines (see isActLogMessage),
+// never against the output of the workflow steps, the workflow commands they issue, or the job and step names.
+var infraFailurePatterns = []struct {
+	reason string
+	regex  *regexp.Regexp
+}{
+	{reason: "docker daemon", regex: regexp.MustCompile(`(?i)cannot connect to the docker daemon|error response from daemon`)},
+	{reason: "docker image pull", regex: regexp.MustCompile(`(?i)failed to pull|error pulling image|pull access denied|toomanyrequests`)},
+	{reason: "git clone", regex: regexp.MustCompile(`(?i)unable to clone|failed to clone|authentication required`)},
+	{reason: "port clash", regex: regexp.MustCompile(`(?i)address already in use`)},
+	{reason: "disk space", regex: regexp.MustCompile(`(?i)no space left on device`)},
+	{reason: "network", regex: regexp.MustCompile(`(?i)tls handshake timeout|i/o timeout|connection reset by peer|no such host|connection refused`)},
+}
+
+// workflowCommandEchoRegex matches a workflow command anywhere in a log message, e.g. "❗  ::error::boom",
+// which act prints when echoing the commands issued by the workflow steps.
+var workflowCommandEchoRegex = regexp.MustCompile(`(?:^|\s)::[A-Za-z][A-Za-z0-9-]*(?: [^:]*)?::`)
+
+// isActLogMessage returns true if the message of the given log line was written by act itself:
+// it's not the output of a step, nor a workflow command issued by a step (or act's echo of it).
+func isActLogMessage(data logLine) bool {
+	return !data.RawOutput && data.Command == "" && !workflowCommandEchoRegex.MatchString(data.Message)
+}
+
+// detectInfraFailure records the first infrastructure failure found in the given (masked) act log line.
+func (r *RunResult) detectInfraFailure(line string) {
+	if r.InfraFailure != nil {
+		return
+	}
+	for _, p := range infraFailurePatterns {
+		if p.regex.MatchString(line) {
+			r.InfraFailure = &InfraError{Reason: p.reason, Line: line}
+			return
+		}
+	}
+}
+
+// RetryPolicy defines how act runs that fail because of the infrastructure (see InfraError) are retried.
+// Workflow failures are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Zero or one disables retries.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. It doubles after every retry.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between retries. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// BeforeRetry, if set, is called before each retry, after the mocks of the Runner have been reset
+	// (see Runner.resetMocks). It can be used to reset the state recorded by the handlers
+	// registered by the test, like the GCOM mock handlers.
+	BeforeRetry func()
+}
+
+// backoff returns the delay before the retry following the given (1-based) failed attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for range attempt - 1 {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	return d
+}
+
+// WithRetryPolicy sets the policy used to retry act runs that fail because of the infrastructure.
+// By default, act runs are never retried, and infrastructure failures are only reported in RunResult.InfraFailure.
+func WithRetryPolicy(policy RetryPolicy) RunnerOption {
+	return func(r *Runner) {
+		r.RetryPolicy = policy
+	}
+}
+
+// runWithRetries runs the given workflow, retrying it according to the Runner's RetryPolicy
+// as long as it fails because of the infrastructure. Retries are logged in the test output.
+// If retries are enabled and all the attempts failed because of the infrastructure, it returns the InfraError.
+func (r *Runner) runWithRetries(ctx context.Context, workflow workflow.Workflow, event Event) (*RunResult, error) {
+	for attempt := 1; ; attempt++ {
+		runResult, err := r.runOnce(ctx, workflow, event)
+		if err != nil || runResult.InfraFailure == nil || r.RetryPolicy.MaxAttempts <= 1 {
+			if runResult != nil {
+				runResult.Attempts = attempt
+			}
+			return runResult, err
+		}
+		runResult.Attempts = attempt
+		if attempt >= r.RetryPolicy.MaxAttempts {
+			return runResult, runResult.InfraFailure
+		}
+		backoff := r.RetryPolicy.backoff(attempt)
+		r.t.Logf("%s: attempt %d/%d failed, retrying in %s: %v", r.name, attempt, r.RetryPolicy.MaxAttempts, backoff, runResult.InfraFailure)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return runResult, runResult.InfraFailure
+		}
+		if err := r.resetMocks(); err != nil {
+			return runResult, fmt.Errorf("reset mocks before retry: %w", err)
+		}
+		if r.RetryPolicy.BeforeRetry != nil {
+			r.RetryPolicy.BeforeRetry()
+		}
+	}
+}
+
+// resetMocks discards what a failed attempt left in the mocks of the Runner, so the retry starts from scratch:
+// the files uploaded to the mock GCS, the uploaded artifacts and the calls recorded by the Argo spy.
+// The handlers registered on the GCOM mock belong to the test, see RetryPolicy.BeforeRetry.
+func (r *Runner) resetMocks() error {
+	// The directories are mounted in the act containers and referenced by GCS.Fs, so only their content is removed
+	for _, dir := range []string{r.GCS.basePath, r.ArtifactsStorage.basePath} {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read %q: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return fmt.Errorf("remove %q: %w", entry.Name(), err)
+			}
+		}
+	}
+	r.ArtifactsStorage.retentions.reset()
+	r.Argo.Reset()
+	return nil
+}
@@ -0,0 +1,156 @@
+package act
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerInfraFailure(t *testing.T) {
+	t.Run("infra failure", func(t *testing.T) {
+		executor := &ScriptedExecutor{
+			Output:   []string{"Error: failed to pull image catthehacker/ubuntu:act-latest: i/o timeout"},
+			ExitCode: 1,
+		}
+		r := newTestRunner(t, executor)
+		res, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+		require.NoError(t, err, "infra failures are only returned as errors when retries are enabled")
+		require.NotNil(t, res)
+		require.False(t, res.Success)
+		require.NotNil(t, res.InfraFailure)
+		require.Equal(t, "docker image pull", res.InfraFailure.Reason)
+		require.Equal(t, 1, res.InfraFailure.ExitCode)
+		require.Equal(t, 1, res.Attempts)
+		require.Len(t, executor.Calls(), 1)
+	})
+
+	t.Run("workflow failure", func(t *testing.T) {
+		executor := &ScriptedExecutor{
+			Output: []string{
+				// Output of the workflow steps is never classified as an infra failure
+				jsonLogLine(t, logLine{JobID: "build", RawOutput: true, Message: "dial tcp: i/o timeout"}),
+			},
+			ExitCode: 1,
+		}
+		r := newTestRunner(t, executor, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+		res, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Nil(t, res.InfraFailure)
+		require.Len(t, executor.Calls(), 1, "workflow failures should not be retried")
+	})
+
+	t.Run("act log message", func(t *testing.T) {
+		executor := &ScriptedExecutor{
+			Output:   []string{jsonLogLine(t, logLine{JobID: "build", Job: "Build", Message: "failed to start container: Error response from daemon: conflict"})},
+			ExitCode: 1,
+		}
+		r := newTestRunner(t, executor)
+		res, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+		require.NoError(t, err)
+		require.NotNil(t, res.InfraFailure)
+		require.Equal(t, "docker daemon", res.InfraFailure.Reason)
+		require.Equal(t, "failed to start container: Error response from daemon: conflict", res.InfraFailure.Line, "only the message should be reported")
+	})
+
+	t.Run("annotations and names are not infra failures", func(t *testing.T) {
+		executor := &ScriptedExecutor{
+			Output: []string{
+				// A step reporting a failure of the system under test as an annotation, and act's echo of it
+				jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Message: "::error::connection refused", Command: "error", Arg: "connection refused"}),
+				jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Message: "\u2757  ::error::connection refused"}),
+				jsonLogLine(t, logLine{JobID: "build", Job: "Build", StepID: []string{"hello"}, Message: "::warning title=Registry::authentication required"}),
+				// The names of the job and of the step are not messages
+				jsonLogLine(t, logLine{JobID: "build", Job: "Check no space left on device", Step: "Test connection refused", StepID: []string{"hello"}, Message: "Run Main Test"}),
+				jsonLogLine(t, logLine{JobID: "build", Job: "Build", JobResult: "failure"}),
+			},
+			ExitCode: 1,
+		}
+		r := newTestRunner(t, executor, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+		res, err := r.Run(newTestWorkflow(workflow.Step{ID: "hello", Run: "echo hello"}), NewPushEventPayload("main"))
+		require.NoError(t, err)
+		require.False(t, res.Success)
+		require.Nil(t, res.InfraFailure)
+		require.Len(t, executor.Calls(), 1, "workflow failures should not be retried")
+		require.Equal(t, Annotations{{Level: AnnotationLevelError, Message: "connection refused", JobID: "build", StepID: "hello"}}, res.Annotations.Filter(AnnotationLevelError, nil))
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		executor := &ScriptedExecutor{
+			Output:   []string{"Error: Cannot connect to the Docker daemon at unix:///var/run/docker.sock"},
+			ExitCode: 1,
+		}
+		r := newTestRunner(t, executor, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+		res, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+		var infraErr *InfraError
+		require.ErrorAs(t, err, &infraErr)
+		require.Equal(t, "docker daemon", infraErr.Reason)
+		require.Same(t, res.InfraFailure, infraErr)
+		require.Equal(t, 3, res.Attempts)
+		require.Len(t, executor.Calls(), 3)
+	})
+}
+
+// sideEffectExecutor is an Executor that calls effect before each execution of the ScriptedExecutor,
+// to simulate what a workflow leaves in the mocks of the Runner.
+type sideEffectExecutor struct {
+	*ScriptedExecutor
+	effect func(attempt int)
+}
+
+func (e *sideEffectExecutor) Execute(ctx context.Context, args []string, env []string) (Execution, error) {
+	e.effect(len(e.Calls()) + 1)
+	return e.ScriptedExecutor.Execute(ctx, args, env)
+}
+
+func TestRunnerRetryResetsMocks(t *testing.T) {
+	var r *Runner
+	executor := &sideEffectExecutor{
+		ScriptedExecutor: &ScriptedExecutor{
+			Output:   []string{"Error: Cannot connect to the Docker daemon at unix:///var/run/docker.sock"},
+			ExitCode: 1,
+		},
+		effect: func(attempt int) {
+			name := fmt.Sprintf("attempt-%d", attempt)
+			require.NoError(t, os.WriteFile(filepath.Join(r.GCS.basePath, name), nil, 0o644))
+			require.NoError(t, os.MkdirAll(filepath.Join(r.ArtifactsStorage.basePath, "run-id", name), 0o755))
+			r.Argo.recordCall(SpyCallInputs{Inputs: map[string]any{"attempt": attempt}})
+		},
+	}
+	var retries int
+	r = newTestRunner(t, executor, WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		BeforeRetry: func() { retries++ },
+	}))
+	_, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+	var infraErr *InfraError
+	require.ErrorAs(t, err, &infraErr)
+	require.Equal(t, 2, retries)
+
+	// Only what the last attempt left is kept
+	gcsFiles, err := afero.ReadDir(r.GCS.Fs, "/")
+	require.NoError(t, err)
+	require.Len(t, gcsFiles, 1)
+	require.Equal(t, "attempt-3", gcsFiles[0].Name())
+	artifacts, err := os.ReadDir(filepath.Join(r.ArtifactsStorage.basePath, "run-id"))
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	require.Equal(t, "attempt-3", artifacts[0].Name())
+	require.Equal(t, []SpyCallInputs{{Inputs: map[string]any{"attempt": 3}}}, r.Argo.GetCalls())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	require.Equal(t, time.Second, p.backoff(1))
+	require.Equal(t, 2*time.Second, p.backoff(2))
+	require.Equal(t, 3*time.Second, p.backoff(3))
+	require.Equal(t, 3*time.Second, p.backoff(100))
+}
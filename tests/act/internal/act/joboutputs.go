@@ -0,0 +1,187 @@
+package act
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+)
+
+var (
+	// outputExpressionRegex matches the ${{ <expression> }} expressions in the value of an output.
+	outputExpressionRegex = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+	// outputReferenceRegex matches a reference to the output of a step or of a job:
+	// steps.<id>.outputs.<name>, needs.<id>.outputs.<name> or jobs.<id>.outputs.<name>.
+	outputReferenceRegex = regexp.MustCompile(`^(steps|needs|jobs)\.([A-Za-z0-9_-]+)\.outputs\.([A-Za-z0-9_-]+)$`)
+
+	// outputStringLiteralRegex matches a string literal in an expression, e.g. 'value'.
+	outputStringLiteralRegex = regexp.MustCompile(`^'((?:[^']|'')*)'$`)
+)
+
+// outputResolver returns the value of the output with the given name of the step or job with the given id.
+// The kind is "steps", "needs" or "jobs", like in the expressions.
+type outputResolver func(kind, id, name string) string
+
+// evaluateOutput evaluates the value of an output declared in a workflow (e.g.: "${{ steps.vars.outputs.version }}"),
+// resolving the references to the outputs of steps and jobs with the given resolver.
+// Missing outputs evaluate to an empty string, like in GitHub Actions.
+// Only references to outputs, string literals and the || operator are supported:
+// it returns false if the value contains other expressions (e.g.: inputs, contexts, functions).
+func evaluateOutput(value string, resolve outputResolver) (string, bool) {
+	ok := true
+	s := outputExpressionRegex.ReplaceAllStringFunc(value, func(m string) string {
+		v, evaluated := evaluateOutputExpression(outputExpressionRegex.FindStringSubmatch(m)[1], resolve)
+		if !evaluated {
+			ok = false
+		}
+		return v
+	})
+	return s, ok
+}
+
+// evaluateOutputExpression evaluates the given expression, without the ${{ }} delimiters.
+// The || operator returns the first non-empty operand, or the last operand if they're all empty.
+func evaluateOutputExpression(expression string, resolve outputResolver) (string, bool) {
+	var v string
+	for _, operand := range splitOrOperands(expression) {
+		operand = strings.TrimSpace(operand)
+		if m := outputReferenceRegex.FindStringSubmatch(operand); m != nil {
+			v = resolve(m[1], m[2], m[3])
+		} else if m := outputStringLiteralRegex.FindStringSubmatch(operand); m != nil {
+			v = strings.ReplaceAll(m[1], "''", "'")
+		} else {
+			return "", false
+		}
+		if v != "" {
+			break
+		}
+	}
+	return v, true
+}
+
+// splitOrOperands splits the given expression into the operands of the || operator.
+// The || inside string literals (e.g.: 'a||b') are not operators.
+func splitOrOperands(expression string) []string {
+	var operands []string
+	inLiteral := false
+	start := 0
+	for i := 0; i < len(expression); i++ {
+		switch {
+		case expression[i] == '\'':
+			// An escaped quote ('') ends the literal and starts it again
+			inLiteral = !inLiteral
+		case !inLiteral && strings.HasPrefix(expression[i:], "||"):
+			operands = append(operands, expression[start:i])
+			start = i + 2
+			i++
+		}
+	}
+	return append(operands, expression[start:])
+}
+
+// jobPathSeparator separates the job IDs in the path of a job of a reusable workflow.
+// Job IDs can only contain alphanumeric characters, "-" and "_", so it can't appear in a job ID.
+const jobPathSeparator = "/"
+
+// JobPath returns the path of a job of a reusable workflow, from the IDs of the jobs calling the reusable workflows
+// and of the job itself (e.g.: JobPath("cd", "ci", "build") is "cd/ci/build"). It can be used to look up JobOutputs.
+func JobPath(jobIDs ...string) string {
+	return strings.Join(jobIDs, jobPathSeparator)
+}
+
+// resolveJobOutputs evaluates the outputs declared by the jobs of the given workflow and its children
+// and records them in JobOutputs. It must be called after the run, when the step outputs have been captured.
+func (r *RunResult) resolveJobOutputs(wf workflow.Workflow) {
+	r.JobOutputs = map[string]map[string]string{}
+	r.workflowJobOutputs(wf, nil)
+}
+
+// workflowJobOutputs evaluates the outputs of the jobs of the given workflow that ran, by job ID,
+// and records them in JobOutputs by JobPath, with the given path of the jobs calling the workflow.
+// A job that ran without declaring outputs has an empty map of outputs.
+// act reports the step outputs by job ID only, so the jobs with the same ID in different reusable workflows
+// are evaluated from the same step outputs.
+func (r *RunResult) workflowJobOutputs(wf workflow.Workflow, path []string) map[string]map[string]string {
+	jobs := wf.Jobs()
+	scope := map[string]map[string]string{}
+	resolved := map[string]bool{}
+
+	var resolveJob func(id string)
+	resolveJob = func(id string) {
+		if resolved[id] {
+			return
+		}
+		resolved[id] = true
+		job, ok := jobs[id]
+		if !ok {
+			return
+		}
+
+		// Jobs calling a reusable workflow have the workflow_call outputs of the workflow as outputs
+		if child := calledChild(wf, job); child != nil {
+			childScope := r.workflowJobOutputs(child, slices.Concat(path, []string{id}))
+			if len(childScope) == 0 {
+				// None of the jobs of the reusable workflow ran
+				return
+			}
+			declared := make(map[string]string, len(child.On.WorkflowCall.Outputs))
+			for name, output := range child.On.WorkflowCall.Outputs {
+				declared[name] = output.Value
+			}
+			scope[id] = evaluateOutputs(declared, func(kind, jobID, name string) string {
+				if kind != "jobs" {
+					return ""
+				}
+				return childScope[jobID][name]
+			})
+			return
+		}
+
+		if result, ok := r.Jobs[id]; !ok || result.Conclusion == ConclusionSkipped {
+			return
+		}
+		scope[id] = evaluateOutputs(job.Outputs, func(kind, refID, name string) string {
+			switch kind {
+			case "steps":
+				v, _ := r.Outputs.Get(id, refID, name)
+				return v
+			case "needs":
+				resolveJob(refID)
+				return scope[refID][name]
+			default:
+				return ""
+			}
+		})
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(jobs)) {
+		resolveJob(id)
+	}
+	for id, outputs := range scope {
+		r.JobOutputs[JobPath(slices.Concat(path, []string{id})...)] = outputs
+	}
+	return scope
+}
+
+// evaluateOutputs evaluates the given declared outputs (output name -> value expression) with the given resolver.
+// The outputs that can't be evaluated (see evaluateOutput) are left out.
+func evaluateOutputs(declared map[string]string, resolve outputResolver) map[string]string {
+	outputs := make(map[string]string, len(declared))
+	for name, value := range declared {
+		if v, ok := evaluateOutput(value, resolve); ok {
+			outputs[name] = v
+		}
+	}
+	return outputs
+}
+
+// JobOutput returns the value of the output with the given name of the given job, as evaluated from the
+// outputs declared by the job. The job is the ID of a job of the top-level workflow,
+// or the path of a job of a reusable workflow (see JobPath). See JobOutputs.
+func (r *RunResult) JobOutput(jobID, outputName string) (string, bool) {
+	v, ok := r.JobOutputs[jobID][outputName]
+	return v, ok
+}
@@ -0,0 +1,197 @@
+package act
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateOutput(t *testing.T) {
+	resolve := func(kind, id, name string) string {
+		if kind == "steps" && id == "vars" && name == "version" {
+			return "1.0.0"
+		}
+		return ""
+	}
+	for _, tc := range []struct {
+		value string
+		exp   string
+		expOK bool
+	}{
+		{value: "${{ steps.vars.outputs.version }}", exp: "1.0.0", expOK: true},
+		{value: "v${{steps.vars.outputs.version}}-linux", exp: "v1.0.0-linux", expOK: true},
+		{value: "${{ steps.missing.outputs.version }}", exp: "", expOK: true},
+		{value: "${{ steps.missing.outputs.version || steps.vars.outputs.version }}", exp: "1.0.0", expOK: true},
+		{value: "${{ steps.missing.outputs.version || 'it''s unknown' }}", exp: "it's unknown", expOK: true},
+		{value: "${{ steps.missing.outputs.version || 'a||b' }}", exp: "a||b", expOK: true},
+		{value: "${{ 'it''s a||b' || steps.vars.outputs.version }}", exp: "it's a||b", expOK: true},
+		{value: "constant", exp: "constant", expOK: true},
+		{value: "${{ inputs.prefix }}dist-artifacts"},
+		{value: "${{ fromJSON(steps.vars.outputs.version) }}"},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			v, ok := evaluateOutput(tc.value, resolve)
+			require.Equal(t, tc.expOK, ok)
+			if tc.expOK {
+				require.Equal(t, tc.exp, v)
+			}
+		})
+	}
+}
+
+func TestResolveJobOutputs(t *testing.T) {
+	child := workflow.NewTestingWorkflow("child", workflow.BaseWorkflow{
+		On: workflow.On{WorkflowCall: workflow.OnWorkflowCall{Outputs: map[string]workflow.WorkflowCallOutput{
+			"version":   {Value: "${{ jobs.build.outputs.version }}"},
+			"published": {Value: "${{ jobs.publish.outputs.url }}"},
+			"prefix":    {Value: "${{ inputs.prefix }}"},
+		}}},
+		Jobs: map[string]*workflow.Job{
+			"build": {Outputs: map[string]string{
+				"version": "${{ steps.vars.outputs.version }}",
+				"zip":     "${{ steps.package.outputs.zip }}",
+			}},
+			"publish": {Needs: []string{"build"}, Outputs: map[string]string{
+				"url": "https://example.com/${{ needs.build.outputs.version }}",
+			}},
+			"docs": {Outputs: map[string]string{"exist": "${{ steps.exist.outputs.exist }}"}},
+		},
+	})
+	wf := workflow.NewTestingWorkflow("parent", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"call-child": {Uses: workflow.PCIWFBaseRef + "/" + child.FileName() + "@main"},
+		"not-run":    {Uses: workflow.PCIWFBaseRef + "/does-not-exist.yml@main"},
+	}})
+	wf.AddChild("child", child)
+
+	r := newRunResult()
+	r.Outputs.Set("build", "vars", "version", "1.0.0")
+	r.Outputs.Set("build", StepPath("package", "zip"), "zip", "plugin.zip")
+	r.Jobs["build"] = &JobResult{ID: "build", Conclusion: ConclusionSuccess}
+	r.Jobs["publish"] = &JobResult{ID: "publish", Conclusion: ConclusionSuccess}
+	r.Jobs["docs"] = &JobResult{ID: "docs", Conclusion: ConclusionSkipped}
+	r.resolveJobOutputs(wf)
+
+	require.Equal(t, map[string]map[string]string{
+		"call-child/build":   {"version": "1.0.0", "zip": "plugin.zip"},
+		"call-child/publish": {"url": "https://example.com/1.0.0"},
+		// Outputs using inputs can't be evaluated
+		"call-child": {"version": "1.0.0", "published": "https://example.com/1.0.0"},
+	}, r.JobOutputs, "skipped jobs and jobs calling workflows that didn't run should have no outputs")
+
+	v, ok := r.JobOutput("call-child", "published")
+	require.True(t, ok)
+	require.Equal(t, "https://example.com/1.0.0", v)
+	_, ok = r.JobOutput(JobPath("call-child", "docs"), "exist")
+	require.False(t, ok)
+}
+
+func TestResolveJobOutputsSiblingWorkflows(t *testing.T) {
+	newChild := func(name, output string) *workflow.TestingWorkflow {
+		return workflow.NewTestingWorkflow(name, workflow.BaseWorkflow{
+			On: workflow.On{WorkflowCall: workflow.OnWorkflowCall{Outputs: map[string]workflow.WorkflowCallOutput{
+				"value": {Value: "${{ jobs.build.outputs.value }}"},
+			}}},
+			Jobs: map[string]*workflow.Job{
+				"build": {Outputs: map[string]string{"value": output}},
+			},
+		})
+	}
+	first := newChild("first", "${{ steps.vars.outputs.first }}")
+	second := newChild("second", "${{ steps.vars.outputs.second }}")
+	wf := workflow.NewTestingWorkflow("parent", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"call-first":  {Uses: workflow.PCIWFBaseRef + "/" + first.FileName() + "@main"},
+		"call-second": {Uses: workflow.PCIWFBaseRef + "/" + second.FileName() + "@main"},
+	}})
+	wf.AddChild("first", first)
+	wf.AddChild("second", second)
+
+	// act reports the step outputs of both "build" jobs under the same job ID
+	r := newRunResult()
+	r.Outputs.Set("build", "vars", "first", "1")
+	r.Outputs.Set("build", "vars", "second", "2")
+	r.Jobs["build"] = &JobResult{ID: "build", Conclusion: ConclusionSuccess}
+	r.resolveJobOutputs(wf)
+
+	require.Equal(t, map[string]map[string]string{
+		"call-first/build":  {"value": "1"},
+		"call-first":        {"value": "1"},
+		"call-second/build": {"value": "2"},
+		"call-second":       {"value": "2"},
+	}, r.JobOutputs, "jobs with the same ID in sibling workflows should not overwrite each other")
+}
+
+func TestResolveJobOutputsSiblingWorkflowsSameOutput(t *testing.T) {
+	newChild := func(name string) *workflow.TestingWorkflow {
+		return workflow.NewTestingWorkflow(name, workflow.BaseWorkflow{
+			On: workflow.On{WorkflowCall: workflow.OnWorkflowCall{Outputs: map[string]workflow.WorkflowCallOutput{
+				"value": {Value: "${{ jobs.build.outputs.value }}"},
+			}}},
+			Jobs: map[string]*workflow.Job{
+				"build": {Outputs: map[string]string{"value": "${{ steps.vars.outputs.value }}"}},
+			},
+		})
+	}
+	first := newChild("first")
+	second := newChild("second")
+	wf := workflow.NewTestingWorkflow("parent", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"call-first":  {Uses: workflow.PCIWFBaseRef + "/" + first.FileName() + "@main"},
+		"call-second": {Uses: workflow.PCIWFBaseRef + "/" + second.FileName() + "@main"},
+	}})
+	wf.AddChild("first", first)
+	wf.AddChild("second", second)
+
+	// Both "build" jobs set the same step output, reported under the same job ID: the last one wins
+	r := newRunResult()
+	r.Outputs.Set("build", "vars", "value", "1")
+	r.Outputs.Set("build", "vars", "value", "2")
+	r.Jobs["build"] = &JobResult{ID: "build", Conclusion: ConclusionSuccess}
+	r.resolveJobOutputs(wf)
+
+	require.Equal(t, map[string]map[string]string{
+		"call-first/build":  {"value": "2"},
+		"call-first":        {"value": "2"},
+		"call-second/build": {"value": "2"},
+		"call-second":       {"value": "2"},
+	}, r.JobOutputs, "jobs with the same ID in sibling workflows share their step outputs (known limitation)")
+}
+
+func TestResolveJobOutputsCDExposesCIOutputs(t *testing.T) {
+	t.Chdir(filepath.Join("..", "..", "..", ".."))
+	ciBase, err := workflow.NewBaseWorkflowFromFile(filepath.Join(".github", "workflows", "ci.yml"))
+	require.NoError(t, err)
+	cdBase, err := workflow.NewBaseWorkflowFromFile(filepath.Join(".github", "workflows", "cd.yml"))
+	require.NoError(t, err)
+	ci := workflow.NewTestingWorkflow("ci", ciBase)
+	cd := workflow.NewTestingWorkflow("cd", cdBase)
+	cd.AddChild("ci", ci)
+	cd.BaseWorkflow.Jobs["ci"].Uses = workflow.PCIWFBaseRef + "/" + ci.FileName() + "@main"
+	wf := workflow.NewTestingWorkflow("simple-cd", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"cd": {Uses: workflow.PCIWFBaseRef + "/" + cd.FileName() + "@main"},
+	}})
+	wf.AddChild("cd", cd)
+
+	// Every job of ci.yml ran, and every step output they reference has a unique value
+	r := newRunResult()
+	stepReferenceRegex := regexp.MustCompile(`steps\.([A-Za-z0-9_-]+)\.outputs\.([A-Za-z0-9_-]+)`)
+	for id, job := range ci.Jobs() {
+		r.Jobs[id] = &JobResult{ID: id, Conclusion: ConclusionSuccess}
+		for _, value := range job.Outputs {
+			for _, m := range stepReferenceRegex.FindAllStringSubmatch(value, -1) {
+				r.Outputs.Set(id, m[1], m[2], id+"/"+m[1]+"/"+m[2])
+			}
+		}
+	}
+	r.resolveJobOutputs(wf)
+
+	ciOutputs := r.JobOutputs[JobPath("cd", "ci")]
+	require.NotEmpty(t, ciOutputs, "ci.yml should have outputs that can be evaluated")
+	for name, value := range ciOutputs {
+		require.NotEmpty(t, value, "output %q of ci.yml", name)
+		cdValue, ok := r.JobOutput("cd", name)
+		require.True(t, ok, "output %q from ci.yml not exposed by cd.yml", name)
+		require.Equal(t, value, cdValue, "output %q of cd.yml should be the one of ci.yml", name)
+	}
+}
@@ -0,0 +1,154 @@
+package act
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+)
+
+// WithJob makes the Runner run only the job with the given ID (and the jobs it needs), like act's -j flag.
+// The job can be defined in the workflow itself or in any of its (nested) child workflows,
+// like the ones created by ci.Workflow and cd.Workflow. In the latter case, the jobs calling the
+// child workflows are run, but the child workflows only run the jobs on the path to the selected job.
+// The workflow itself is not modified.
+func WithJob(jobID string) RunnerOption {
+	return func(r *Runner) {
+		r.jobID = jobID
+	}
+}
+
+// WithMatrix makes the Runner run only the matrix combinations where the given key has the given value,
+// like act's --matrix flag. It can be used multiple times to filter on multiple keys.
+func WithMatrix(key, value string) RunnerOption {
+	return func(r *Runner) {
+		r.matrix = append(r.matrix, key+":"+value)
+	}
+}
+
+// jobPathElement is a job in a workflow, on the path to the selected job.
+type jobPathElement struct {
+	workflow workflow.Workflow
+	jobID    string
+}
+
+// jobSelection is the selected job resolved in a workflow and its children.
+type jobSelection struct {
+	// path goes from the job in the top-level workflow to the selected job,
+	// through the jobs calling the child workflows.
+	path []jobPathElement
+}
+
+// resolveJobSelection resolves the Runner's selected job in the given workflow and its children.
+// If the job is defined in multiple workflows, the one closest to the top-level workflow is selected.
+// It returns nil if no job is selected.
+func (r *Runner) resolveJobSelection(wf workflow.Workflow) (*jobSelection, error) {
+	if r.jobID == "" {
+		return nil, nil
+	}
+	paths := findJobPaths(wf, r.jobID)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("job %q not found in workflow or its children, available jobs: %s", r.jobID, strings.Join(allJobIDs(wf), ", "))
+	}
+	slices.SortStableFunc(paths, func(a, b []jobPathElement) int {
+		return len(a) - len(b)
+	})
+	if len(paths) > 1 && len(paths[0]) == len(paths[1]) {
+		return nil, fmt.Errorf("job %q is ambiguous, it's defined in multiple child workflows at the same level", r.jobID)
+	}
+	return &jobSelection{path: paths[0]}, nil
+}
+
+// findJobPaths returns all the paths to the job with the given ID in the given workflow and its children.
+func findJobPaths(wf workflow.Workflow, jobID string) [][]jobPathElement {
+	jobs := wf.Jobs()
+	if _, ok := jobs[jobID]; ok {
+		return [][]jobPathElement{{{workflow: wf, jobID: jobID}}}
+	}
+	var paths [][]jobPathElement
+	for _, id := range slices.Sorted(maps.Keys(jobs)) {
+		child := calledChild(wf, jobs[id])
+		if child == nil {
+			continue
+		}
+		for _, childPath := range findJobPaths(child, jobID) {
+			paths = append(paths, append([]jobPathElement{{workflow: wf, jobID: id}}, childPath...))
+		}
+	}
+	return paths
+}
+
+// calledChild returns the child workflow called by the given job, or nil if the job doesn't call a child workflow.
+func calledChild(wf workflow.Workflow, job *workflow.Job) *workflow.TestingWorkflow {
+	if job.Uses == "" {
+		return nil
+	}
+	for _, child := range wf.Children() {
+		if strings.Contains(job.Uses, "/"+child.FileName()) {
+			return child
+		}
+	}
+	return nil
+}
+
+// allJobIDs returns the sorted IDs of all the jobs in the given workflow and its children.
+func allJobIDs(wf workflow.Workflow) []string {
+	ids := map[string]struct{}{}
+	for _, jobs := range allWorkflowJobs(wf) {
+		for id := range jobs {
+			ids[id] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(ids))
+}
+
+// args returns the act CLI arguments to run the job selected in the top-level workflow.
+func (s *jobSelection) args() []string {
+	return []string{"-j", s.path[0].jobID}
+}
+
+// writeChildWorkflowFiles overwrites the temporary files of the child workflows on the path to the selected job,
+// created by CreateTempWorkflowFile, so they only contain the job on the path and the jobs it needs.
+func (s *jobSelection) writeChildWorkflowFiles() error {
+	for _, el := range s.path[1:] {
+		child := el.workflow.(*workflow.TestingWorkflow)
+		filtered := child.BaseWorkflow
+		filtered.Jobs = map[string]*workflow.Job{}
+		for _, id := range jobWithDependencies(child.Jobs(), el.jobID) {
+			filtered.Jobs[id] = child.Jobs()[id]
+		}
+		content, err := filtered.Marshal()
+		if err != nil {
+			return fmt.Errorf("marshal filtered workflow: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(".github", "workflows", child.FileName()), content, 0o644); err != nil {
+			return fmt.Errorf("write filtered workflow file: %w", err)
+		}
+	}
+	return nil
+}
+
+// jobWithDependencies returns the given job ID and the IDs of the jobs it needs, directly or indirectly.
+func jobWithDependencies(jobs map[string]*workflow.Job, jobID string) []string {
+	seen := map[string]struct{}{}
+	var visit func(id string)
+	visit = func(id string) {
+		if _, ok := seen[id]; ok {
+			return
+		}
+		job, ok := jobs[id]
+		if !ok {
+			return
+		}
+		seen[id] = struct{}{}
+		for _, need := range job.Needs {
+			visit(need)
+		}
+	}
+	visit(jobID)
+	return slices.Sorted(maps.Keys(seen))
+}
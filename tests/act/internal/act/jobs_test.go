@@ -0,0 +1,92 @@
+package act
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow/ci"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerWithJob(t *testing.T) {
+	t.Run("nested job", func(t *testing.T) {
+		executor := &ScriptedExecutor{}
+		r := newTestRunner(t, executor, WithJob("test-and-build"), WithMatrix("os", "linux"))
+		wf, err := ci.NewWorkflow()
+		require.NoError(t, err)
+		_, err = r.Run(wf, NewPushEventPayload("main"))
+		require.NoError(t, err)
+
+		// The top-level job calling the child workflow is selected
+		args := executor.Calls()[0]
+		require.Equal(t, "ci", args[slices.Index(args, "-j")+1])
+		require.Equal(t, "os:linux", args[slices.Index(args, "--matrix")+1])
+
+		// The child workflow only contains the selected job and its dependencies
+		child, err := workflow.NewBaseWorkflowFromFile(filepath.Join(".github", "workflows", wf.GetChild("ci").FileName()))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"check-for-release-channel", "get-workflow-run-id", "test-and-build"}, slices.Collect(maps.Keys(child.Jobs)))
+
+		// The workflow itself is not modified
+		require.Contains(t, wf.GetChild("ci").Jobs(), "upload-to-gcs")
+	})
+
+	t.Run("top-level job", func(t *testing.T) {
+		executor := &ScriptedExecutor{}
+		r := newTestRunner(t, executor, WithJob("build"))
+		_, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+		require.NoError(t, err)
+		args := executor.Calls()[0]
+		require.Equal(t, "build", args[slices.Index(args, "-j")+1])
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		executor := &ScriptedExecutor{}
+		r := newTestRunner(t, executor, WithJob("does-not-exist"))
+		_, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+		require.ErrorContains(t, err, `job "does-not-exist" not found in workflow or its children, available jobs: build, get-workflow-run-id`)
+		require.Empty(t, executor.Calls())
+	})
+}
+
+func TestFindJobPaths(t *testing.T) {
+	grandchild := workflow.NewTestingWorkflow("grandchild", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"deep": {},
+	}})
+	child := workflow.NewTestingWorkflow("child", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"call-grandchild": {Uses: "./.github/workflows/" + grandchild.FileName()},
+	}})
+	child.AddChild("grandchild", grandchild)
+	wf := workflow.NewTestingWorkflow("parent", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+		"call-child": {Uses: workflow.PCIWFBaseRef + "/" + child.FileName() + "@main"},
+	}})
+	wf.AddChild("child", child)
+
+	paths := findJobPaths(wf, "deep")
+	require.Len(t, paths, 1)
+	var jobIDs []string
+	for _, el := range paths[0] {
+		jobIDs = append(jobIDs, el.jobID)
+	}
+	require.Equal(t, []string{"call-child", "call-grandchild", "deep"}, jobIDs)
+
+	// get-workflow-run-id is defined at every level: the top-level one is selected
+	r := &Runner{jobID: "get-workflow-run-id"}
+	selection, err := r.resolveJobSelection(wf)
+	require.NoError(t, err)
+	require.Len(t, selection.path, 1)
+}
+
+func TestJobWithDependencies(t *testing.T) {
+	jobs := map[string]*workflow.Job{
+		"a": {},
+		"b": {Needs: []string{"a"}},
+		"c": {Needs: []string{"b"}},
+		"d": {},
+	}
+	require.Equal(t, []string{"a", "b", "c"}, jobWithDependencies(jobs, "c"))
+	require.Equal(t, []string{"d"}, jobWithDependencies(jobs, "d"))
+}
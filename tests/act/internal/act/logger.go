@@ -4,18 +4,26 @@ import "time"
 
 // logLine represents a single line in act's JSON log output.
 type logLine struct {
-	DryRun bool   `json:"dryrun"`
-	Job    string `json:"job"`
-	JobID  string `json:"jobID"`
-	Level  string `json:"level"`
-	// TODO: type
-	Matrix  any       `json:"matrix"`
+	DryRun  bool      `json:"dryrun"`
+	Job     string    `json:"job"`
+	JobID   string    `json:"jobID"`
+	Level   string    `json:"level"`
+	Matrix  Matrix    `json:"matrix"`
 	Message string    `json:"msg"`
 	Stage   string    `json:"stage"`
 	Step    string    `json:"step"`
 	StepID  []string  `json:"stepID"`
 	Time    time.Time `json:"time"`
 
+	// RawOutput is true for lines printed by the workflow steps, rather than by act itself.
+	RawOutput bool `json:"raw_output,omitempty"`
+
+	// JobResult is set on the last log line of a job, when act reports its result (e.g.: "success", "failure").
+	JobResult string `json:"jobResult,omitempty"`
+
+	// StepResult is set on the last log line of each stage of a step, when act reports its result.
+	StepResult string `json:"stepResult,omitempty"`
+
 	// Intercepted GHA commands
 
 	Command string `json:"command,omitempty"`
@@ -0,0 +1,162 @@
+package act
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Matrix is the combination of matrix values of a matrix job leg (e.g.: {"environment": "dev"}),
+// as reported by act in its log lines. Jobs without a matrix have an empty Matrix.
+// Values decoded from JSON are strings, float64 numbers, booleans, or nested maps and slices.
+type Matrix map[string]any
+
+// Key returns the canonical representation of the Matrix: the JSON encoding of its values, with the keys sorted
+// (e.g.: {"environment":"dev","os":"linux"}), or an empty string if the Matrix is empty.
+// Numbers compare equal regardless of their Go type, but the string "1" and the number 1 are different values.
+func (m Matrix) Key() string {
+	if len(m) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(map[string]any(m))
+	if err != nil {
+		// fmt prints the maps with the keys sorted as well
+		return fmt.Sprint(map[string]any(m))
+	}
+	return string(b)
+}
+
+// String returns the Key of the Matrix.
+func (m Matrix) String() string {
+	return m.Key()
+}
+
+// Matches returns true if the Matrix has all the values of the given (partial) matrix.
+// An empty matrix matches all matrices.
+func (m Matrix) Matches(matrix Matrix) bool {
+	for k, v := range matrix {
+		mv, ok := m[k]
+		if !ok || matrixValueString(mv) != matrixValueString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixValueString returns the string representation of the given matrix value, used to compare values.
+func matrixValueString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// matrixOutputs are the outputs of a single matrix job leg.
+type matrixOutputs struct {
+	matrix Matrix
+
+	// data is a map of step id -> output name (keys) -> output value (value)
+	data map[string]map[string]string
+}
+
+// SetMatrix sets the output value for the given job ID, matrix leg, step ID and output name.
+// The value is also set as the output of the job itself, so Get returns the value of the last leg that set it.
+func (o Outputs) SetMatrix(jobID string, matrix Matrix, stepID, outputName, value string) {
+	o.Set(jobID, stepID, outputName, value)
+	if len(matrix) == 0 {
+		return
+	}
+	legs, ok := o.matrix[jobID]
+	if !ok {
+		legs = map[string]*matrixOutputs{}
+		o.matrix[jobID] = legs
+	}
+	key := matrix.Key()
+	leg, ok := legs[key]
+	if !ok {
+		leg = &matrixOutputs{matrix: matrix, data: map[string]map[string]string{}}
+		legs[key] = leg
+	}
+	setStepOutput(leg.data, stepID, outputName, value)
+}
+
+// GetMatrix retrieves the output value for the given job ID, matrix leg, step ID and output name.
+// The matrix can be partial (e.g.: only {"environment": "dev"} for a job with an environment x os matrix),
+// as long as it matches a single leg of the job. An empty matrix is the same as Get.
+func (o Outputs) GetMatrix(jobID string, matrix Matrix, stepID, outputName string) (string, bool) {
+	if len(matrix) == 0 {
+		return o.Get(jobID, stepID, outputName)
+	}
+	leg, ok := o.matrix[jobID][matrix.Key()]
+	if !ok {
+		var matches []*matrixOutputs
+		for _, l := range o.matrix[jobID] {
+			if l.matrix.Matches(matrix) {
+				matches = append(matches, l)
+			}
+		}
+		if len(matches) != 1 {
+			return "", false
+		}
+		leg = matches[0]
+	}
+	value, ok := leg.data[stepID][outputName]
+	return value, ok
+}
+
+// Matrices returns the matrix legs of the given job that set outputs, sorted by Key.
+func (o Outputs) Matrices(jobID string) []Matrix {
+	matrices := make([]Matrix, 0, len(o.matrix[jobID]))
+	for _, key := range slices.Sorted(maps.Keys(o.matrix[jobID])) {
+		matrices = append(matrices, o.matrix[jobID][key].matrix)
+	}
+	return matrices
+}
+
+// AnnotationsForMatrix returns the annotations raised by the legs of the given job matching the given (partial) matrix, in order.
+func (r *RunResult) AnnotationsForMatrix(jobID string, matrix Matrix) Annotations {
+	var annotations Annotations
+	for _, annotation := range r.AnnotationsFor(jobID) {
+		if annotation.Matrix.Matches(matrix) {
+			annotations = append(annotations, annotation)
+		}
+	}
+	return annotations
+}
+
+// setEnv sets the environment variable exported by the given job (and matrix leg, if any) in Env and MatrixEnv.
+func (r *RunResult) setEnv(jobID string, matrix Matrix, name, value string) {
+	if r.Env[jobID] == nil {
+		r.Env[jobID] = map[string]string{}
+	}
+	r.Env[jobID][name] = value
+	if len(matrix) == 0 {
+		return
+	}
+	if r.MatrixEnv[jobID] == nil {
+		r.MatrixEnv[jobID] = map[string]map[string]string{}
+	}
+	key := matrix.Key()
+	if r.MatrixEnv[jobID][key] == nil {
+		r.MatrixEnv[jobID][key] = map[string]string{}
+	}
+	r.MatrixEnv[jobID][key][name] = value
+}
+
+// addPath adds the path added by the given job (and matrix leg, if any) to Paths and MatrixPaths.
+func (r *RunResult) addPath(jobID string, matrix Matrix, path string) {
+	r.Paths[jobID] = append(r.Paths[jobID], path)
+	if len(matrix) == 0 {
+		return
+	}
+	if r.MatrixPaths[jobID] == nil {
+		r.MatrixPaths[jobID] = map[string][]string{}
+	}
+	key := matrix.Key()
+	r.MatrixPaths[jobID][key] = append(r.MatrixPaths[jobID][key], path)
+}
@@ -0,0 +1,106 @@
+package act
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrix(t *testing.T) {
+	m := Matrix{"os": "linux", "environment": "dev", "node": float64(20), "canary": true}
+	require.Equal(t, `{"canary":true,"environment":"dev","node":20,"os":"linux"}`, m.Key())
+	require.Equal(t, m.Key(), Matrix{"os": "linux", "environment": "dev", "node": 20, "canary": true}.Key())
+	// Values with separators or of different types don't collide
+	require.NotEqual(t, Matrix{"a": "1,b:2"}.Key(), Matrix{"a": "1", "b": "2"}.Key())
+	require.NotEqual(t, Matrix{"a": "1"}.Key(), Matrix{"a": 1}.Key())
+	require.True(t, m.Matches(Matrix{"environment": "dev", "node": 20}))
+	require.True(t, m.Matches(nil))
+	require.False(t, m.Matches(Matrix{"environment": "ops"}))
+	require.False(t, m.Matches(Matrix{"arch": "amd64"}))
+	require.Empty(t, Matrix(nil).Key())
+}
+
+func TestMatrixOutputs(t *testing.T) {
+	r := &Runner{name: t.Name()}
+	res := newRunResult()
+	stream := strings.Join([]string{
+		`{"jobID":"publish","matrix":{"environment":"dev","os":"linux"},"stepID":["publish"],"command":"set-output","name":"url","arg":"https://dev"}`,
+		`{"jobID":"publish","matrix":{"environment":"ops","os":"linux"},"stepID":["publish"],"command":"set-output","name":"url","arg":"https://ops"}`,
+		`{"jobID":"publish","matrix":{"environment":"ops","os":"linux"},"stepID":["publish"],"command":"warning","arg":"slow rollout"}`,
+		`{"jobID":"setup","matrix":{},"stepID":["vars"],"command":"set-output","name":"environments","arg":"[\"dev\",\"ops\"]"}`,
+	}, "\n")
+	require.NoError(t, r.processStream(strings.NewReader(stream), &res, nil))
+
+	for _, tc := range []struct {
+		name   string
+		matrix Matrix
+		exp    string
+		expOK  bool
+	}{
+		{name: "exact", matrix: Matrix{"environment": "dev", "os": "linux"}, exp: "https://dev", expOK: true},
+		{name: "partial", matrix: Matrix{"environment": "ops"}, exp: "https://ops", expOK: true},
+		{name: "ambiguous", matrix: Matrix{"os": "linux"}},
+		{name: "no leg", matrix: Matrix{"environment": "prod"}},
+		{name: "empty is last leg", exp: "https://ops", expOK: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, ok := res.Outputs.GetMatrix("publish", tc.matrix, "publish", "url")
+			require.Equal(t, tc.expOK, ok)
+			require.Equal(t, tc.exp, v)
+		})
+	}
+
+	require.Equal(t, []Matrix{
+		{"environment": "dev", "os": "linux"},
+		{"environment": "ops", "os": "linux"},
+	}, res.Outputs.Matrices("publish"))
+	require.Empty(t, res.Outputs.Matrices("setup"), "jobs without a matrix have no legs")
+	v, ok := res.Outputs.GetMatrix("setup", nil, "vars", "environments")
+	require.True(t, ok)
+	require.Equal(t, `["dev","ops"]`, v)
+
+	require.Len(t, res.AnnotationsForMatrix("publish", Matrix{"environment": "ops"}), 1)
+	require.Empty(t, res.AnnotationsForMatrix("publish", Matrix{"environment": "dev"}))
+
+	snapshot := res.Snapshot()
+	require.Equal(t, map[string][]MatrixLegSnapshot{"publish": {
+		{Matrix: Matrix{"environment": "dev", "os": "linux"}, Outputs: map[string]map[string]string{"publish": {"url": "https://dev"}}},
+		{Matrix: Matrix{"environment": "ops", "os": "linux"}, Outputs: map[string]map[string]string{"publish": {"url": "https://ops"}}},
+	}}, snapshot.MatrixOutputs, "the snapshot should include the outputs of each leg")
+}
+
+func TestMatrixCommands(t *testing.T) {
+	r := &Runner{name: t.Name()}
+	res := newRunResult()
+	// The legs run in parallel, so their log lines are interleaved
+	stream := strings.Join([]string{
+		`{"jobID":"build","matrix":{"os":"linux"},"stepID":["build"],"command":"group","arg":"Build linux"}`,
+		`{"jobID":"build","matrix":{"os":"darwin"},"stepID":["build"],"command":"group","arg":"Build darwin"}`,
+		`{"jobID":"build","matrix":{"os":"linux"},"stepID":["build"],"msg":"building linux"}`,
+		`{"jobID":"build","matrix":{"os":"darwin"},"stepID":["build"],"msg":"building darwin"}`,
+		`{"jobID":"build","matrix":{"os":"linux"},"stepID":["build"],"command":"endgroup"}`,
+		`{"jobID":"build","matrix":{"os":"darwin"},"stepID":["build"],"msg":"still building darwin"}`,
+		`{"jobID":"build","matrix":{"os":"darwin"},"stepID":["build"],"command":"endgroup"}`,
+		`{"jobID":"build","matrix":{"os":"linux"},"stepID":["build"],"command":"set-env","name":"GOOS","arg":"linux"}`,
+		`{"jobID":"build","matrix":{"os":"darwin"},"stepID":["build"],"command":"set-env","name":"GOOS","arg":"darwin"}`,
+		`{"jobID":"build","matrix":{"os":"linux"},"stepID":["build"],"command":"add-path","arg":"/opt/linux/bin"}`,
+		`{"jobID":"build","matrix":{"os":"darwin"},"stepID":["build"],"command":"add-path","arg":"/opt/darwin/bin"}`,
+		`{"jobID":"setup","matrix":{},"stepID":["vars"],"command":"set-env","name":"ENV","arg":"dev"}`,
+	}, "\n")
+	require.NoError(t, r.processStream(strings.NewReader(stream), &res, nil))
+
+	linux, darwin := Matrix{"os": "linux"}, Matrix{"os": "darwin"}
+	require.Equal(t, []*LogGroup{
+		{JobID: "build", Matrix: linux, StepID: "build", Title: "Build linux", Lines: []string{"building linux"}},
+		{JobID: "build", Matrix: darwin, StepID: "build", Title: "Build darwin", Lines: []string{"building darwin", "still building darwin"}},
+	}, res.LogGroups)
+	require.Equal(t, map[string]map[string]map[string]string{
+		"build": {linux.Key(): {"GOOS": "linux"}, darwin.Key(): {"GOOS": "darwin"}},
+	}, res.MatrixEnv)
+	require.Equal(t, map[string]map[string][]string{
+		"build": {linux.Key(): {"/opt/linux/bin"}, darwin.Key(): {"/opt/darwin/bin"}},
+	}, res.MatrixPaths)
+	require.Equal(t, map[string]map[string]string{"build": {"GOOS": "darwin"}, "setup": {"ENV": "dev"}}, res.Env, "the last leg should win in Env")
+	require.Equal(t, []string{"/opt/linux/bin", "/opt/darwin/bin"}, res.Paths["build"])
+}
@@ -0,0 +1,82 @@
+package act
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Output is a single output of a step, as iterated by Outputs.All.
+type Output struct {
+	// JobID is the ID of the job of the step.
+	JobID string
+
+	// StepID is the ID of the step, or the path of a step nested in composite actions (see StepPath).
+	StepID string
+
+	// Name is the name of the output.
+	Name string
+
+	// Value is the value of the output.
+	Value string
+}
+
+// All returns an iterator over all the outputs, sorted by job ID, step ID and name.
+// The outputs of steps nested in composite actions are yielded for both their path and their top-level step.
+func (o Outputs) All() iter.Seq[Output] {
+	return func(yield func(Output) bool) {
+		for _, jobID := range slices.Sorted(maps.Keys(o.data)) {
+			steps := o.data[jobID]
+			for _, stepID := range slices.Sorted(maps.Keys(steps)) {
+				outputs := steps[stepID]
+				for _, name := range slices.Sorted(maps.Keys(outputs)) {
+					if !yield(Output{JobID: jobID, StepID: stepID, Name: name, Value: outputs[name]}) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Lookup is like Get, but it returns a descriptive error if the output is not found,
+// listing the outputs of the step (or the steps of the job with outputs) that do exist.
+func (o Outputs) Lookup(jobID, stepID, outputName string) (string, error) {
+	if v, ok := o.Get(jobID, stepID, outputName); ok {
+		return v, nil
+	}
+	steps, ok := o.data[jobID]
+	if !ok {
+		return "", fmt.Errorf("output %q not found: job %q has no outputs, jobs with outputs: %s", outputName, jobID, joinKeys(o.data))
+	}
+	outputs, ok := steps[stepID]
+	if !ok {
+		return "", fmt.Errorf("output %q not found: step %q of job %q has no outputs, steps with outputs: %s", outputName, stepID, jobID, joinKeys(steps))
+	}
+	return "", fmt.Errorf("output %q not found for step %q of job %q, available outputs: %s", outputName, stepID, jobID, joinKeys(outputs))
+}
+
+// joinKeys returns the sorted keys of the given map, joined with a comma, or "none" if the map is empty.
+func joinKeys[V any](m map[string]V) string {
+	if len(m) == 0 {
+		return "none"
+	}
+	return strings.Join(slices.Sorted(maps.Keys(m)), ", ")
+}
+
+// OutputJSON decodes the JSON value of the output with the given name of the given job and step into a T.
+// It returns an error if the output doesn't exist (see Outputs.Lookup) or if it's not valid JSON for T.
+func OutputJSON[T any](r *RunResult, jobID, stepID, outputName string) (T, error) {
+	var v T
+	raw, err := r.Outputs.Lookup(jobID, stepID, outputName)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return v, fmt.Errorf("unmarshal output %q of step %q of job %q: %w", outputName, stepID, jobID, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,69 @@
+package act
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputs(t *testing.T) {
+	r := newRunResult()
+	r.Outputs.Set("setup", "vars", "environments", `["dev","ops"]`)
+	r.Outputs.Set("setup", "vars", "publish-docs", "true")
+	r.Outputs.Set("setup", "workflow-context", "result", `{"isTrusted":true,"isForkPR":false}`)
+	r.Outputs.Set("build", StepPath("package", "zip"), "zip", "plugin.zip")
+
+	t.Run("all", func(t *testing.T) {
+		require.Equal(t, []Output{
+			{JobID: "build", StepID: "package", Name: "zip", Value: "plugin.zip"},
+			{JobID: "build", StepID: "package/zip", Name: "zip", Value: "plugin.zip"},
+			{JobID: "setup", StepID: "vars", Name: "environments", Value: `["dev","ops"]`},
+			{JobID: "setup", StepID: "vars", Name: "publish-docs", Value: "true"},
+			{JobID: "setup", StepID: "workflow-context", Name: "result", Value: `{"isTrusted":true,"isForkPR":false}`},
+		}, slices.Collect(r.Outputs.All()))
+
+		// Stops when the caller breaks
+		var n int
+		for range r.Outputs.All() {
+			n++
+			break
+		}
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		environments, err := OutputJSON[[]string](&r, "setup", "vars", "environments")
+		require.NoError(t, err)
+		require.Equal(t, []string{"dev", "ops"}, environments)
+
+		publishDocs, err := OutputJSON[bool](&r, "setup", "vars", "publish-docs")
+		require.NoError(t, err)
+		require.True(t, publishDocs)
+
+		context, err := OutputJSON[struct {
+			IsTrusted bool `json:"isTrusted"`
+			IsForkPR  bool `json:"isForkPR"`
+		}](&r, "setup", "workflow-context", "result")
+		require.NoError(t, err)
+		require.True(t, context.IsTrusted)
+		require.False(t, context.IsForkPR)
+
+		_, err = OutputJSON[[]string](&r, "build", "package", "zip")
+		require.ErrorContains(t, err, `unmarshal output "zip" of step "package" of job "build"`)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := OutputJSON[[]string](&r, "setup", "vars", "platforms")
+		require.EqualError(t, err, `output "platforms" not found for step "vars" of job "setup", available outputs: environments, publish-docs`)
+
+		_, err = r.Outputs.Lookup("setup", "checkout", "commit")
+		require.EqualError(t, err, `output "commit" not found: step "checkout" of job "setup" has no outputs, steps with outputs: vars, workflow-context`)
+
+		_, err = r.Outputs.Lookup("deploy", "vars", "environments")
+		require.EqualError(t, err, `output "environments" not found: job "deploy" has no outputs, jobs with outputs: build, setup`)
+
+		_, err = newRunResult().Outputs.Lookup("deploy", "vars", "environments")
+		require.EqualError(t, err, `output "environments" not found: job "deploy" has no outputs, jobs with outputs: none`)
+	})
+}
@@ -0,0 +1,228 @@
+package act
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The typed event payloads below follow the structure of the GitHub webhook event payloads:
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads
+// They only contain the fields that are relevant for testing the workflows.
+// Fields that change the behavior of the workflows when set (e.g.: SHAs, which take precedence
+// over the SHA of the local repository) are omitted from the JSON unless explicitly set.
+
+// fixedTimestamp is the timestamp used in the event payloads, so they are reproducible.
+const fixedTimestamp = "2025-01-01T00:00:00Z"
+
+// User is a GitHub user (or organization) in an event payload.
+type User struct {
+	Login string `json:"login"`
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+}
+
+// Repository is a GitHub repository in an event payload.
+type Repository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Private       bool   `json:"private"`
+	Owner         User   `json:"owner"`
+	HTMLURL       string `json:"html_url"`
+	Fork          bool   `json:"fork"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// CommitAuthor is the author or committer of a commit in an event payload.
+type CommitAuthor struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username,omitempty"`
+}
+
+// Commit is a commit in a push event payload.
+type Commit struct {
+	ID        string       `json:"id"`
+	TreeID    string       `json:"tree_id"`
+	Message   string       `json:"message"`
+	Timestamp string       `json:"timestamp"`
+	Author    CommitAuthor `json:"author"`
+	Committer CommitAuthor `json:"committer"`
+}
+
+// PushPayload is the payload of a push event.
+type PushPayload struct {
+	Ref        string       `json:"ref"`
+	Before     string       `json:"before,omitempty"`
+	After      string       `json:"after,omitempty"`
+	BaseRef    *string      `json:"base_ref"`
+	Created    bool         `json:"created"`
+	Deleted    bool         `json:"deleted"`
+	Forced     bool         `json:"forced"`
+	Commits    []Commit     `json:"commits"`
+	HeadCommit *Commit      `json:"head_commit"`
+	Repository Repository   `json:"repository"`
+	Pusher     CommitAuthor `json:"pusher"`
+	Sender     User         `json:"sender"`
+}
+
+// Label is a label of a pull request in an event payload.
+type Label struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// PullRequestRef is the head or base of a pull request in an event payload.
+type PullRequestRef struct {
+	Label string     `json:"label"`
+	Ref   string     `json:"ref"`
+	SHA   string     `json:"sha,omitempty"`
+	User  User       `json:"user"`
+	Repo  Repository `json:"repo"`
+}
+
+// PullRequest is a pull request in an event payload.
+type PullRequest struct {
+	ID       int64          `json:"id"`
+	Number   int            `json:"number"`
+	State    string         `json:"state"`
+	Title    string         `json:"title"`
+	User     User           `json:"user"`
+	Draft    bool           `json:"draft"`
+	Merged   bool           `json:"merged"`
+	MergedAt *string        `json:"merged_at"`
+	Labels   []Label        `json:"labels"`
+	Head     PullRequestRef `json:"head"`
+	Base     PullRequestRef `json:"base"`
+}
+
+// PullRequestPayload is the payload of a pull_request (or pull_request_target) event.
+type PullRequestPayload struct {
+	Action      string      `json:"action"`
+	Number      int         `json:"number"`
+	PullRequest PullRequest `json:"pull_request"`
+	Repository  Repository  `json:"repository"`
+	Sender      User        `json:"sender"`
+}
+
+// Review is a pull request review in an event payload.
+type Review struct {
+	ID    int64  `json:"id"`
+	State string `json:"state"`
+	User  User   `json:"user"`
+}
+
+// PullRequestReviewPayload is the payload of a pull_request_review event.
+type PullRequestReviewPayload struct {
+	Action      string      `json:"action"`
+	Review      Review      `json:"review"`
+	PullRequest PullRequest `json:"pull_request"`
+	Repository  Repository  `json:"repository"`
+	Sender      User        `json:"sender"`
+}
+
+// Release is a release in an event payload.
+type Release struct {
+	ID              int64   `json:"id"`
+	TagName         string  `json:"tag_name"`
+	TargetCommitish string  `json:"target_commitish"`
+	Name            string  `json:"name"`
+	Draft           bool    `json:"draft"`
+	Prerelease      bool    `json:"prerelease"`
+	CreatedAt       string  `json:"created_at"`
+	PublishedAt     *string `json:"published_at"`
+	HTMLURL         string  `json:"html_url"`
+	Author          User    `json:"author"`
+}
+
+// ReleasePayload is the payload of a release event.
+type ReleasePayload struct {
+	Action     string     `json:"action"`
+	Release    Release    `json:"release"`
+	Repository Repository `json:"repository"`
+	Sender     User       `json:"sender"`
+}
+
+// WorkflowDispatchPayload is the payload of a workflow_dispatch event.
+type WorkflowDispatchPayload struct {
+	Inputs     map[string]any `json:"inputs"`
+	Ref        string         `json:"ref,omitempty"`
+	Workflow   string         `json:"workflow"`
+	Repository Repository     `json:"repository"`
+	Sender     User           `json:"sender"`
+}
+
+// repository returns the repository used in the event payloads.
+func repository() Repository {
+	return Repository{
+		ID:            1,
+		Name:          "plugin-ci-workflows",
+		FullName:      repositoryFullName,
+		Owner:         User{Login: "grafana", ID: 1, Type: "Organization"},
+		HTMLURL:       "https://github.com/" + repositoryFullName,
+		DefaultBranch: "main",
+	}
+}
+
+// sender returns the user that triggered the events.
+func sender() User {
+	return User{Login: "nektos/act", ID: 2, Type: "User"}
+}
+
+// pullRequest returns a pull request from the given branch to main of the same repository.
+func pullRequest(prBranch string) PullRequest {
+	return PullRequest{
+		ID:     1,
+		Number: 1,
+		State:  "open",
+		Title:  "Pull request from " + prBranch,
+		User:   sender(),
+		Labels: []Label{},
+		Head: PullRequestRef{
+			Label: "grafana:" + prBranch,
+			Ref:   prBranch,
+			User:  repository().Owner,
+			Repo:  repository(),
+		},
+		Base: PullRequestRef{
+			Label: "grafana:main",
+			Ref:   "main",
+			User:  repository().Owner,
+			Repo:  repository(),
+		},
+	}
+}
+
+// payloadPullRequest returns the pull request of the given event payload,
+// or nil if the payload doesn't contain a (typed) pull request.
+func payloadPullRequest(payload any) *PullRequest {
+	switch p := payload.(type) {
+	case *PullRequestPayload:
+		return &p.PullRequest
+	case *PullRequestReviewPayload:
+		return &p.PullRequest
+	default:
+		return nil
+	}
+}
+
+// marshalPayload returns the JSON of the given event payload, with the additional "act": true key-value pair.
+func marshalPayload(payload any) ([]byte, error) {
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["act"] = true
+	return json.Marshal(fields)
+}
@@ -0,0 +1,122 @@
+package act
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+)
+
+// nektosActRunnerImage is the (multi-arch) image used by default for all the runner labels.
+const nektosActRunnerImage = "ghcr.io/catthehacker/ubuntu:act-latest"
+
+// RunnerImage is the Docker image that act uses to run the jobs for a runner label (`runs-on`),
+// for each container architecture.
+type RunnerImage struct {
+	// AMD64 is the image used for the linux/amd64 container architecture.
+	// If empty, the label can't run on linux/amd64.
+	AMD64 string
+
+	// ARM64 is the image used for the linux/arm64 container architecture.
+	// If empty, the label can't run on linux/arm64.
+	ARM64 string
+}
+
+// MultiArchRunnerImage returns a RunnerImage that uses the given multi-arch image for all architectures.
+func MultiArchRunnerImage(image string) RunnerImage {
+	return RunnerImage{AMD64: image, ARM64: image}
+}
+
+// forArchitecture returns the image for the given container architecture (e.g.: "linux/arm64" or "arm64").
+func (i RunnerImage) forArchitecture(architecture string) string {
+	switch strings.TrimPrefix(architecture, "linux/") {
+	case "amd64":
+		return i.AMD64
+	case "arm64":
+		return i.ARM64
+	default:
+		return ""
+	}
+}
+
+// DefaultRunnerImages maps the runner labels used by the workflows to the images used by act.
+// The self-hosted runner labels are not known to act, so jobs using them are silently skipped unless mapped.
+// Use WithRunnerImage to add or override labels for a Runner.
+var DefaultRunnerImages = map[string]RunnerImage{
+	"ubuntu-latest":        MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-x64-small":     MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-x64":           MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-x64-large":     MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-x64-xlarge":    MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-x64-xlarge-io": MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-x64-2xlarge":   MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-arm64-small":   MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-arm64":         MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-arm64-large":   MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-arm64-xlarge":  MultiArchRunnerImage(nektosActRunnerImage),
+	"ubuntu-arm64-2xlarge": MultiArchRunnerImage(nektosActRunnerImage),
+}
+
+// WithRunnerImage maps the given runner label to the given image, in addition to DefaultRunnerImages.
+func WithRunnerImage(label string, image RunnerImage) RunnerOption {
+	return func(r *Runner) {
+		r.runnerImages[label] = image
+	}
+}
+
+// containerArchitecture returns the container architecture act uses for the jobs:
+// the Runner's ContainerArchitecture if set, or the one of the host otherwise.
+func (r *Runner) containerArchitecture() string {
+	if r.ContainerArchitecture != "" {
+		return r.ContainerArchitecture
+	}
+	return "linux/" + runtime.GOARCH
+}
+
+// platformArgs returns the act CLI arguments (-P) that map the runner labels used by the given workflow
+// and its children to images, for the container architecture of the Runner.
+// Labels defined via expressions can't be resolved before running, so all the known labels are mapped in that case.
+// It returns an error if a label is not mapped to an image, since act would silently skip the job.
+func (r *Runner) platformArgs(wf workflow.Workflow) ([]string, error) {
+	architecture := r.containerArchitecture()
+	labels := map[string]struct{}{}
+	var errs []string
+	for _, jobs := range allWorkflowJobs(wf) {
+		for _, id := range slices.Sorted(maps.Keys(jobs)) {
+			job := jobs[id]
+			if job.Uses != "" || job.RunsOn == "" {
+				continue
+			}
+			if strings.Contains(job.RunsOn, "${{") {
+				for label := range r.runnerImages {
+					labels[label] = struct{}{}
+				}
+				continue
+			}
+			image, ok := r.runnerImages[job.RunsOn]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("job %q: runner label %q is not mapped to an image", id, job.RunsOn))
+				continue
+			}
+			if image.forArchitecture(architecture) == "" {
+				errs = append(errs, fmt.Sprintf("job %q: runner label %q has no image for %s", id, job.RunsOn, architecture))
+				continue
+			}
+			labels[job.RunsOn] = struct{}{}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("map runner labels (use WithRunnerImage): %s", strings.Join(errs, "; "))
+	}
+
+	var args []string
+	for _, label := range slices.Sorted(maps.Keys(labels)) {
+		if image := r.runnerImages[label].forArchitecture(architecture); image != "" {
+			args = append(args, "-P", label+"="+image)
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,70 @@
+package act
+
+import (
+	"testing"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerPlatformArgs(t *testing.T) {
+	newWorkflow := func(runsOn ...string) *workflow.TestingWorkflow {
+		jobs := map[string]*workflow.Job{}
+		for i, label := range runsOn {
+			jobs[string(rune('a'+i))] = &workflow.Job{RunsOn: label}
+		}
+		child := workflow.NewTestingWorkflow("child", workflow.BaseWorkflow{Jobs: map[string]*workflow.Job{
+			"child-job": {RunsOn: "ubuntu-x64-large"},
+		}})
+		wf := workflow.NewTestingWorkflow("test", workflow.BaseWorkflow{Jobs: jobs})
+		wf.AddChild("child", child)
+		return wf
+	}
+
+	t.Run("labels from workflow and children", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{})
+		args, err := r.platformArgs(newWorkflow("ubuntu-x64-small"))
+		require.NoError(t, err)
+		// get-workflow-run-id job runs on ubuntu-arm64-small
+		require.Equal(t, []string{
+			"-P", "ubuntu-arm64-small=" + nektosActRunnerImage,
+			"-P", "ubuntu-x64-large=" + nektosActRunnerImage,
+			"-P", "ubuntu-x64-small=" + nektosActRunnerImage,
+		}, args)
+	})
+
+	t.Run("unmapped label", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{})
+		_, err := r.platformArgs(newWorkflow("some-new-runner"))
+		require.ErrorContains(t, err, `runner label "some-new-runner" is not mapped to an image`)
+
+		// Unmapped labels fail before running act
+		executor := &ScriptedExecutor{}
+		r = newTestRunner(t, executor)
+		_, err = r.Run(newWorkflow("some-new-runner"), NewPushEventPayload("main"))
+		require.Error(t, err)
+		require.Empty(t, executor.Calls())
+	})
+
+	t.Run("architecture", func(t *testing.T) {
+		r := newTestRunner(
+			t, &ScriptedExecutor{},
+			WithRunnerImage("custom", RunnerImage{AMD64: "custom:amd64"}),
+			WithLinuxAMD64ContainerArchitecture(),
+		)
+		args, err := r.platformArgs(newWorkflow("custom"))
+		require.NoError(t, err)
+		require.Contains(t, args, "custom=custom:amd64")
+
+		r.ContainerArchitecture = "linux/arm64"
+		_, err = r.platformArgs(newWorkflow("custom"))
+		require.ErrorContains(t, err, `runner label "custom" has no image for linux/arm64`)
+	})
+
+	t.Run("expression", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{})
+		args, err := r.platformArgs(newWorkflow("${{ inputs.runs-on }}"))
+		require.NoError(t, err)
+		require.Len(t, args, 2*len(DefaultRunnerImages))
+	})
+}
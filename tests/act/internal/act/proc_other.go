@@ -0,0 +1,16 @@
+//go:build !unix
+
+package act
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without process groups.
+func setProcessGroup(*exec.Cmd) {}
+
+// killProcessGroup kills the given process only, since there are no process groups on this platform.
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
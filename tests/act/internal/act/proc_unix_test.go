@@ -0,0 +1,50 @@
+//go:build unix
+
+package act
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeActScript is a fake act executable: it reports that the "build" job started,
+// then waits on a child process holding its output open, like act waiting on a job container.
+const fakeActScript = `#!/bin/sh
+echo '{"jobID":"build","job":"Build","msg":"Starting job","time":"2025-01-01T00:00:00Z"}'
+sleep 60 &
+wait
+`
+
+func TestRunContextKillsProcessTree(t *testing.T) {
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "act"), []byte(fakeActScript), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "gh"), []byte("#!/bin/sh\n"), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	t.Chdir(filepath.Join("..", "..", "..", ".."))
+
+	r, err := NewRunner(t, WithTimeout(time.Second), WithActionsCachePath(TemplateActionsCachePath))
+	require.NoError(t, err)
+	wf := workflow.NewTestingWorkflow("unit-test", workflow.BaseWorkflow{
+		Name: "Unit test",
+		Jobs: map[string]*workflow.Job{
+			"build": {Name: "Build", RunsOn: "ubuntu-arm64-small", Steps: []workflow.Step{{Run: "sleep infinity"}}},
+		},
+	})
+	t.Cleanup(func() { _ = os.Remove(filepath.Join(".github", "workflows", wf.FileName())) })
+
+	start := time.Now()
+	res, err := r.RunContext(context.Background(), wf, NewPushEventPayload("main"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotNil(t, res, "should return a partial result")
+	require.True(t, res.Cancelled)
+	require.Equal(t, []string{"build"}, res.RunningJobs)
+	// The output is only closed before the grace period if the child of act has been killed as well.
+	require.Less(t, time.Since(start), killGracePeriod, "the whole act process tree should be killed")
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package act
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request, which makes a file share the extents of another one (reflink).
+const ficlone = 0x40049409
+
+// reflinkFile creates dst as a copy-on-write clone of src.
+// It fails if the file system doesn't support reflinks (e.g.: ext4) or src and dst are on different file systems.
+func reflinkFile(src, dst string, mode os.FileMode) (err error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open source file: %w", err)
+	}
+	defer func() {
+		if closeErr := srcFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("create destination file: %w", err)
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dstFile.Fd(), ficlone, srcFile.Fd())
+	closeErr := dstFile.Close()
+	if errno != 0 {
+		// Do not leave an empty file behind, so the caller can fall back to another method
+		_ = os.Remove(dst)
+		return fmt.Errorf("ioctl FICLONE: %w", errno)
+	}
+	return closeErr
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package act
+
+import (
+	"errors"
+	"os"
+)
+
+// reflinkFile is not supported on this platform, so it always returns errors.ErrUnsupported.
+func reflinkFile(string, string, os.FileMode) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,133 @@
+package act
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// RecordDirEnv is the environment variable that enables recording for all Runners.
+// When set, each Runner records the act output of its runs to a fixture file in this directory,
+// named after the test (see WithRecording).
+const RecordDirEnv = "ACT_RECORD_DIR"
+
+// recordingTrailerPrefix is the prefix of the last line of a recording, which contains act's exit code.
+const recordingTrailerPrefix = "# act-recording exit-code="
+
+// fixtureNameRegex matches the characters that are replaced when deriving a fixture file name from a test name.
+var fixtureNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WithRecording makes the Runner record the raw merged stdout/stderr stream of act to the given fixture file,
+// followed by a trailer line with act's exit code. The fixture can then be replayed with NewReplayRunner
+// without running act. If the Runner runs more than one workflow, the fixture contains the output of the last run.
+//
+// Secret values are masked in the string values of the recorded lines (see WithMaskedValues), so the replayed
+// RunResult is not always the same as the live one. The logs, annotations and Commands are masked in the live
+// run too, so they match. The values containing secrets in Outputs, JobOutputs, State, Env, Paths, Masks,
+// Summary and StepSummaries are kept as-is in the live run, but are replayed as ***.
+func WithRecording(fixturePath string) RunnerOption {
+	return func(r *Runner) {
+		r.recordingPath = fixturePath
+	}
+}
+
+// recordingPathFromEnv returns the fixture path for the given test if RecordDirEnv is set, or an empty string otherwise.
+func recordingPathFromEnv(t *testing.T) string {
+	dir := os.Getenv(RecordDirEnv)
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, fixtureNameRegex.ReplaceAllString(t.Name(), "_")+".log")
+}
+
+// createRecording creates the fixture file at the given path, creating parent directories as needed.
+func createRecording(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir recording directory: %w", err)
+	}
+	return os.Create(path)
+}
+
+// finishRecording writes the trailer with the given exit code to the recording and closes it.
+func finishRecording(f *os.File, exitCode int) error {
+	if _, err := fmt.Fprintf(f, "%s%d\n", recordingTrailerPrefix, exitCode); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write recording trailer: %w", err)
+	}
+	return f.Close()
+}
+
+// parseRecording parses the content of a recording, returning its lines (without the trailer) and the exit code.
+// If the recording has no trailer, the exit code is 0.
+func parseRecording(content []byte) (lines []string, exitCode int, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if code, ok := strings.CutPrefix(line, recordingTrailerPrefix); ok {
+			exitCode, err = strconv.Atoi(code)
+			if err != nil {
+				return nil, 0, fmt.Errorf("parse recorded exit code %q: %w", code, err)
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("scanner error: %w", err)
+	}
+	return lines, exitCode, nil
+}
+
+// ReplayExecutor is an Executor that replays a recorded act output (see WithRecording) instead of running act.
+type ReplayExecutor struct {
+	fixturePath string
+}
+
+// NewReplayExecutor creates a new ReplayExecutor for the given fixture file.
+func NewReplayExecutor(fixturePath string) *ReplayExecutor {
+	return &ReplayExecutor{fixturePath: fixturePath}
+}
+
+// Execute replays the recorded act output and exit code.
+func (e *ReplayExecutor) Execute(ctx context.Context, args []string, env []string) (Execution, error) {
+	content, err := os.ReadFile(e.fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+	lines, exitCode, err := parseRecording(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse recording %q: %w", e.fixturePath, err)
+	}
+	return (&ScriptedExecutor{Output: lines, ExitCode: exitCode}).Execute(ctx, args, env)
+}
+
+// ReplayRunner is a Runner that replays a recorded act output instead of running act.
+// It can be used as a drop-in replacement for a Runner to re-check the assertions of a test
+// against a recorded run in seconds, and as a regression fixture for changes of act's log format.
+// Since no workflow actually runs, side effects such as artifacts, mock GCS uploads
+// and mock HTTP calls are not available.
+type ReplayRunner struct {
+	*Runner
+}
+
+// NewReplayRunner creates a new ReplayRunner that replays the given fixture, recorded via WithRecording.
+func NewReplayRunner(t *testing.T, fixturePath string, opts ...RunnerOption) (*ReplayRunner, error) {
+	// Replays don't need GitHub credentials nor resources for act, but the caller can still override these options.
+	opts = append([]RunnerOption{WithTokenProvider(AnonymousTokenProvider{}), WithScheduler(nil)}, opts...)
+	r, err := NewRunner(t, append(opts, WithExecutor(NewReplayExecutor(fixturePath)), WithRecording(""))...)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayRunner{Runner: r}, nil
+}
+
+// Static checks
+
+var _ Executor = &ReplayExecutor{}
@@ -0,0 +1,357 @@
+package act
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+)
+
+const (
+	// SchedulerCPUEnv is the environment variable that sets the CPU budget (in cores) of the DefaultScheduler.
+	// By default, the budget is the number of CPU cores of the host.
+	SchedulerCPUEnv = "ACT_SCHEDULER_CPU"
+
+	// SchedulerMemoryEnv is the environment variable that sets the memory budget (in MiB) of the DefaultScheduler.
+	// By default, memory is not limited.
+	SchedulerMemoryEnv = "ACT_SCHEDULER_MEMORY_MB"
+
+	// SchedulerContainersEnv is the environment variable that sets the maximum number of job containers
+	// of the DefaultScheduler. By default, it's twice the number of CPU cores of the host.
+	SchedulerContainersEnv = "ACT_SCHEDULER_CONTAINERS"
+)
+
+// Resources is an amount of resources used by act runs.
+// A zero value for a field of a budget means that resource is not limited.
+type Resources struct {
+	// CPU is the number of CPU cores.
+	CPU float64
+
+	// MemoryMB is the amount of memory in MiB.
+	MemoryMB int
+
+	// Containers is the number of job containers.
+	Containers int
+}
+
+// add returns the sum of the two Resources.
+func (r Resources) add(other Resources) Resources {
+	return Resources{
+		CPU:        r.CPU + other.CPU,
+		MemoryMB:   r.MemoryMB + other.MemoryMB,
+		Containers: r.Containers + other.Containers,
+	}
+}
+
+// sub returns the difference of the two Resources.
+func (r Resources) sub(other Resources) Resources {
+	return Resources{
+		CPU:        r.CPU - other.CPU,
+		MemoryMB:   r.MemoryMB - other.MemoryMB,
+		Containers: r.Containers - other.Containers,
+	}
+}
+
+// fits returns true if the Resources fit in the given budget.
+func (r Resources) fits(budget Resources) bool {
+	return (budget.CPU <= 0 || r.CPU <= budget.CPU) &&
+		(budget.MemoryMB <= 0 || r.MemoryMB <= budget.MemoryMB) &&
+		(budget.Containers <= 0 || r.Containers <= budget.Containers)
+}
+
+// clamp returns the Resources capped to the given budget, so a run heavier than the whole budget can still run alone.
+func (r Resources) clamp(budget Resources) Resources {
+	if budget.CPU > 0 {
+		r.CPU = min(r.CPU, budget.CPU)
+	}
+	if budget.MemoryMB > 0 {
+		r.MemoryMB = min(r.MemoryMB, budget.MemoryMB)
+	}
+	if budget.Containers > 0 {
+		r.Containers = min(r.Containers, budget.Containers)
+	}
+	return r
+}
+
+// Scheduler admits act runs based on a resource budget shared by all the Runners using it.
+// Runs are admitted in FIFO order, as soon as their weight fits in the unused budget,
+// so heavy runs are not starved by lighter ones.
+type Scheduler struct {
+	// Budget is the total amount of resources that can be used by concurrent act runs.
+	Budget Resources
+
+	// JobWeight is the estimated amount of resources used by a single job container.
+	JobWeight Resources
+
+	mu      sync.Mutex
+	used    Resources
+	waiters []*schedulerWaiter
+}
+
+// schedulerWaiter is an act run waiting to be admitted by the Scheduler.
+type schedulerWaiter struct {
+	weight   Resources
+	admitted chan struct{}
+}
+
+// DefaultJobWeight is the default estimated amount of resources used by a single job container.
+// Most steps of the tested workflows are mocked, so a job container spends most of its time
+// waiting on I/O and uses about half a CPU core.
+var DefaultJobWeight = Resources{CPU: 0.5, MemoryMB: 1024, Containers: 1}
+
+// DefaultScheduler is the process-wide Scheduler used by all Runners, unless overridden with WithScheduler.
+// Its budget can be configured via the SchedulerCPUEnv, SchedulerMemoryEnv and SchedulerContainersEnv environment variables.
+var DefaultScheduler = NewScheduler(schedulerBudgetFromEnv())
+
+// NewScheduler creates a new Scheduler with the given budget and DefaultJobWeight.
+func NewScheduler(budget Resources) *Scheduler {
+	return &Scheduler{
+		Budget:    budget,
+		JobWeight: DefaultJobWeight,
+	}
+}
+
+// defaultSchedulerBudget returns the budget of the DefaultScheduler based on the host,
+// when it's not configured via the environment variables.
+func defaultSchedulerBudget() Resources {
+	return Resources{
+		CPU:        float64(runtime.NumCPU()),
+		Containers: runtime.NumCPU() * 2,
+	}
+}
+
+// schedulerBudgetFromEnv returns the budget of the DefaultScheduler, based on the host and the environment variables.
+// Invalid values are reported and ignored.
+func schedulerBudgetFromEnv() Resources {
+	budget := defaultSchedulerBudget()
+	if v, ok := os.LookupEnv(SchedulerCPUEnv); ok {
+		cpu, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid %s value %q: %v\n", SchedulerCPUEnv, v, err)
+		} else {
+			budget.CPU = cpu
+		}
+	}
+	for env, dst := range map[string]*int{
+		SchedulerMemoryEnv:     &budget.MemoryMB,
+		SchedulerContainersEnv: &budget.Containers,
+	} {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid %s value %q: %v\n", env, v, err)
+			continue
+		}
+		*dst = n
+	}
+	return budget
+}
+
+// Acquire blocks until the given weight fits in the unused budget, or the context is done.
+// Weights heavier than the whole budget are capped to it, so they run alone.
+// On success, the caller must call the returned release function when the act run is finished.
+func (s *Scheduler) Acquire(ctx context.Context, weight Resources) (release func(), err error) {
+	weight = weight.clamp(s.Budget)
+	w := &schedulerWaiter{weight: weight, admitted: make(chan struct{})}
+
+	s.mu.Lock()
+	s.waiters = append(s.waiters, w)
+	s.admitWaiters()
+	s.mu.Unlock()
+
+	select {
+	case <-w.admitted:
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.admitted:
+			// Admitted concurrently: give the resources back
+			s.used = s.used.sub(weight)
+		default:
+			s.removeWaiter(w)
+		}
+		s.admitWaiters()
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.used = s.used.sub(weight)
+			s.admitWaiters()
+		})
+	}, nil
+}
+
+// admitWaiters admits the waiters at the head of the queue, as long as they fit in the unused budget.
+// The caller must hold s.mu.
+func (s *Scheduler) admitWaiters() {
+	for len(s.waiters) > 0 {
+		w := s.waiters[0]
+		if !s.used.add(w.weight).fits(s.Budget) {
+			return
+		}
+		s.used = s.used.add(w.weight)
+		s.waiters = s.waiters[1:]
+		close(w.admitted)
+	}
+}
+
+// removeWaiter removes the given waiter from the queue.
+// The caller must hold s.mu.
+func (s *Scheduler) removeWaiter(w *schedulerWaiter) {
+	for i, other := range s.waiters {
+		if other == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Weight returns the estimated resources used by running the given workflow (and its children) with act.
+// Each job, or each matrix combination of a job, runs in its own container, but a job only starts
+// once the jobs it needs are done. So the weight is the widest stage of the workflow (see stageWidths),
+// not the total number of jobs.
+// At most concurrentJobs containers run at the same time (0 means the number of CPU cores, like act).
+func (s *Scheduler) Weight(wf workflow.Workflow, concurrentJobs int) Resources {
+	containers := 0
+	for _, width := range stageWidths(wf) {
+		containers = max(containers, width)
+	}
+	if concurrentJobs <= 0 {
+		concurrentJobs = runtime.NumCPU()
+	}
+	containers = max(1, min(containers, concurrentJobs))
+	return Resources{
+		CPU:        s.JobWeight.CPU * float64(containers),
+		MemoryMB:   s.JobWeight.MemoryMB * containers,
+		Containers: s.JobWeight.Containers * containers,
+	}
+}
+
+// stageWidths returns the estimated number of job containers of each stage of the given workflow.
+// The stage of a job is the length of the longest chain of needs leading to it, so the jobs
+// of the same stage can run at the same time.
+// The jobs of a called reusable workflow run in the stages following the stage of the calling job,
+// once per matrix combination of the calling job.
+func stageWidths(wf workflow.Workflow) []int {
+	jobs := wf.Jobs()
+	stages := make(map[string]int, len(jobs))
+	var stage func(id string) int
+	stage = func(id string) int {
+		if s, ok := stages[id]; ok {
+			return s
+		}
+		// Guard against dependency cycles, which act refuses to run anyway
+		stages[id] = 0
+		s := 0
+		if job, ok := jobs[id]; ok {
+			for _, need := range job.Needs {
+				if _, ok := jobs[need]; ok {
+					s = max(s, stage(need)+1)
+				}
+			}
+		}
+		stages[id] = s
+		return s
+	}
+
+	var widths []int
+	addWidth := func(stage, width int) {
+		for len(widths) <= stage {
+			widths = append(widths, 0)
+		}
+		widths[stage] += width
+	}
+	for id, job := range jobs {
+		if job.Uses == "" {
+			addWidth(stage(id), matrixSize(job.Strategy.Matrix))
+			continue
+		}
+		child := calledChild(wf, job)
+		if child == nil {
+			// Reusable workflows that are not part of the test are not run
+			continue
+		}
+		calls := matrixSize(job.Strategy.Matrix)
+		for i, width := range stageWidths(child) {
+			addWidth(stage(id)+i, width*calls)
+		}
+	}
+	return widths
+}
+
+// allWorkflowJobs returns the jobs of the given workflow and all of its children, recursively.
+func allWorkflowJobs(wf workflow.Workflow) []map[string]*workflow.Job {
+	jobs := []map[string]*workflow.Job{wf.Jobs()}
+	for _, child := range wf.Children() {
+		jobs = append(jobs, child.Jobs())
+		for _, grandchild := range child.ChildrenRecursive() {
+			jobs = append(jobs, grandchild.Jobs())
+		}
+	}
+	return jobs
+}
+
+// matrixSize returns the estimated number of combinations of the given matrix.
+// Dimensions defined via expressions (e.g.: fromJSON) can't be resolved statically and count as one value.
+func matrixSize(matrix map[string]any) int {
+	size := 1
+	dimensions := 0
+	for k, v := range matrix {
+		if k == "include" || k == "exclude" {
+			continue
+		}
+		dimensions++
+		if values, ok := v.([]any); ok {
+			size *= max(1, len(values))
+		}
+	}
+	if exclude, ok := matrix["exclude"].([]any); ok {
+		size = max(1, size-len(exclude))
+	}
+	if include, ok := matrix["include"].([]any); ok {
+		if dimensions == 0 {
+			// Only includes: one combination per include
+			size = max(1, len(include))
+		}
+		// Otherwise includes are usually merged into the existing combinations, so they are not counted
+	}
+	return size
+}
+
+// WithScheduler sets the Scheduler that admits the act runs of the Runner.
+// By default, the Runner uses DefaultScheduler. A nil Scheduler disables admission control.
+func WithScheduler(scheduler *Scheduler) RunnerOption {
+	return func(r *Runner) {
+		r.scheduler = scheduler
+	}
+}
+
+// schedule waits until the Scheduler of the Runner admits running the given workflow.
+// The queue time is logged and returned. The caller must call release when the act run is finished.
+func (r *Runner) schedule(ctx context.Context, wf workflow.Workflow) (release func(), queueTime time.Duration, err error) {
+	if r.scheduler == nil {
+		return func() {}, 0, nil
+	}
+	start := time.Now()
+	weight := r.scheduler.Weight(wf, r.ConcurrentJobs)
+	release, err = r.scheduler.Acquire(ctx, weight)
+	queueTime = time.Since(start)
+	if err != nil {
+		return nil, queueTime, fmt.Errorf("wait for act run admission: %w", err)
+	}
+	r.t.Logf("%s: act run admitted after %s in queue (weight: %+v)", r.name, queueTime.Round(time.Millisecond), weight)
+	return release, queueTime, nil
+}
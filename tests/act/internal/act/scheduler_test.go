@@ -0,0 +1,120 @@
+package act
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow/ci"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerAcquire(t *testing.T) {
+	s := NewScheduler(Resources{CPU: 4, Containers: 4})
+
+	releaseFirst, err := s.Acquire(context.Background(), Resources{CPU: 3, Containers: 3})
+	require.NoError(t, err)
+
+	// The second run doesn't fit until the first one is released
+	admitted := make(chan func())
+	go func() {
+		release, err := s.Acquire(context.Background(), Resources{CPU: 2, Containers: 2})
+		require.NoError(t, err)
+		admitted <- release
+	}()
+
+	// Runs behind in the queue are not admitted before it, even if they fit
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	time.Sleep(10 * time.Millisecond)
+	_, err = s.Acquire(ctx, Resources{CPU: 1, Containers: 1})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-admitted:
+		t.Fatal("second run should not be admitted yet")
+	default:
+	}
+	releaseFirst()
+	releaseSecond := <-admitted
+	releaseSecond()
+	releaseSecond() // Releasing twice is a no-op
+
+	// Runs heavier than the whole budget run alone
+	release, err := s.Acquire(context.Background(), Resources{CPU: 100, Containers: 100})
+	require.NoError(t, err)
+	release()
+	require.Equal(t, Resources{}, s.used)
+	require.Empty(t, s.waiters)
+}
+
+func TestSchedulerWeight(t *testing.T) {
+	s := NewScheduler(Resources{})
+	s.JobWeight = Resources{CPU: 0.5, MemoryMB: 100, Containers: 1}
+	wf := workflow.NewTestingWorkflow("test", workflow.BaseWorkflow{
+		Jobs: map[string]*workflow.Job{
+			"single": {},
+			"matrix": {Strategy: workflow.Strategy{Matrix: map[string]any{
+				"os":      []any{"linux", "darwin", "windows"},
+				"arch":    []any{"amd64", "arm64"},
+				"exclude": []any{map[string]any{"os": "windows", "arch": "arm64"}},
+			}}},
+			"include": {Strategy: workflow.Strategy{Matrix: map[string]any{
+				"include": []any{map[string]any{"a": 1}, map[string]any{"a": 2}},
+			}}},
+			"dynamic": {Needs: []string{"single"}, Strategy: workflow.Strategy{Matrix: map[string]any{
+				"target": "${{ fromJSON(needs.setup.outputs.targets) }}",
+			}}},
+			"call":     {},
+			"external": {Uses: "grafana/other/.github/workflows/other.yml@main"},
+		},
+	})
+	child := workflow.NewTestingWorkflow("child", workflow.BaseWorkflow{
+		Jobs: map[string]*workflow.Job{
+			"a": {},
+			"b": {},
+		},
+	})
+	wf.AddChild("child", child)
+	wf.BaseWorkflow.Jobs["call"].Uses = workflow.PCIWFBaseRef + "/" + child.FileName() + "@main"
+
+	// get-workflow-run-id, then single + matrix (3*2-1) + include (2) + the child get-workflow-run-id,
+	// then dynamic (1) + the child jobs (2)
+	require.Equal(t, []int{1, 9, 3}, stageWidths(wf))
+	require.Equal(t, Resources{CPU: 4.5, MemoryMB: 900, Containers: 9}, s.Weight(wf, 100))
+	// Capped by the number of concurrent jobs
+	require.Equal(t, Resources{CPU: 1, MemoryMB: 200, Containers: 2}, s.Weight(wf, 2))
+}
+
+func TestSchedulerWeightCI(t *testing.T) {
+	t.Chdir(filepath.Join("..", "..", "..", ".."))
+	s := NewScheduler(defaultSchedulerBudget())
+	var releases []func()
+	for range 2 {
+		wf, err := ci.NewWorkflow()
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		release, err := s.Acquire(ctx, s.Weight(wf, 0))
+		require.NoError(t, err, "two ci.yml runs should be admitted at the same time")
+		releases = append(releases, release)
+	}
+	for _, release := range releases {
+		release()
+	}
+}
+
+func TestRunnerQueueTime(t *testing.T) {
+	s := NewScheduler(Resources{Containers: 1})
+	release, err := s.Acquire(context.Background(), Resources{Containers: 1})
+	require.NoError(t, err)
+
+	// The queue time doesn't count toward the timeout
+	r := newTestRunner(t, &ScriptedExecutor{}, WithScheduler(s), WithTimeout(50*time.Millisecond))
+	time.AfterFunc(100*time.Millisecond, release)
+	res, err := r.Run(newTestWorkflow(), NewPushEventPayload("main"))
+	require.NoError(t, err)
+	require.Greater(t, res.QueueTime, r.Timeout)
+}
@@ -0,0 +1,291 @@
+package act
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maskedValue is the replacement for masked values in logs, like in GitHub Actions.
+const maskedValue = "***"
+
+// secretMasker replaces secret values with *** in log lines.
+// Multi-line values are masked line by line, like in GitHub Actions.
+type secretMasker struct {
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// newSecretMasker creates a new secretMasker that masks the given values.
+func newSecretMasker(values ...string) *secretMasker {
+	m := &secretMasker{values: map[string]struct{}{}}
+	m.add(values...)
+	return m
+}
+
+// add adds the given values to the values to mask.
+// Empty values are ignored.
+func (m *secretMasker) add(values ...string) {
+	added := false
+	for _, value := range values {
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			// Also mask the JSON-escaped value, in case it's printed inside a JSON log line
+			escaped, _ := json.Marshal(line)
+			for _, v := range []string{line, string(escaped[1 : len(escaped)-1])} {
+				if _, ok := m.values[v]; ok {
+					continue
+				}
+				m.values[v] = struct{}{}
+				added = true
+			}
+		}
+	}
+	if !added {
+		return
+	}
+
+	// Replace longer values first, so values that contain other values are fully masked
+	sorted := make([]string, 0, len(m.values))
+	for v := range m.values {
+		sorted = append(sorted, v)
+	}
+	slices.SortFunc(sorted, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	oldnew := make([]string, 0, len(sorted)*2)
+	for _, v := range sorted {
+		oldnew = append(oldnew, v, maskedValue)
+	}
+	m.replacer = strings.NewReplacer(oldnew...)
+}
+
+// mask returns the given string with all the secret values replaced by ***.
+func (m *secretMasker) mask(s string) string {
+	if m.replacer == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// maskJSON returns the given JSON log line with the secret values in its string values replaced by ***.
+// Keys and other literals are left untouched, so masking a value like "job" or "true" keeps the line valid JSON.
+// The line is returned as-is if there's nothing to mask, and masked as plain text if it's not valid JSON.
+func (m *secretMasker) maskJSON(line []byte) string {
+	if m.replacer == nil {
+		return string(line)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return m.mask(string(line))
+	}
+	masked, changed := m.maskJSONValue(v)
+	if !changed {
+		return string(line)
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(masked); err != nil {
+		return m.mask(string(line))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// maskJSONValue masks the string values in the given decoded JSON value, recursively.
+// It returns true if any value was masked.
+func (m *secretMasker) maskJSONValue(v any) (any, bool) {
+	changed := false
+	switch v := v.(type) {
+	case string:
+		masked := m.mask(v)
+		return masked, masked != v
+	case map[string]any:
+		for k, item := range v {
+			masked, c := m.maskJSONValue(item)
+			v[k] = masked
+			changed = changed || c
+		}
+	case []any:
+		for i, item := range v {
+			masked, c := m.maskJSONValue(item)
+			v[i] = masked
+			changed = changed || c
+		}
+	}
+	return v, changed
+}
+
+// WithMaskedValues adds the given values to the values masked in the act output printed by the Runner
+// and in the stored log captures (log groups and recordings).
+// The GitHub token and the values masked via the add-mask workflow command are always masked.
+func WithMaskedValues(values ...string) RunnerOption {
+	return func(r *Runner) {
+		r.maskedValues = append(r.maskedValues, values...)
+	}
+}
+
+// WithSecret sets a secret available to the workflow as `secrets.<name>`.
+// The secret value is masked in the act output.
+func WithSecret(name, value string) RunnerOption {
+	return func(r *Runner) {
+		r.secrets[name] = value
+	}
+}
+
+// WithSecretsFile adds a dotenv file (KEY=VALUE lines) with secrets available to the workflow.
+// Secrets set via WithSecret take precedence over the ones in the file.
+// Multiple files can be added; later files take precedence over earlier ones.
+// The secret values are masked in the act output.
+func WithSecretsFile(path string) RunnerOption {
+	return func(r *Runner) {
+		r.secretsFiles = append(r.secretsFiles, path)
+	}
+}
+
+// WithVariable sets a configuration variable available to the workflow as `vars.<name>`.
+func WithVariable(name, value string) RunnerOption {
+	return func(r *Runner) {
+		r.variables[name] = value
+	}
+}
+
+// WithEnv sets an environment variable available to all the jobs of the workflow.
+func WithEnv(name, value string) RunnerOption {
+	return func(r *Runner) {
+		r.env[name] = value
+	}
+}
+
+// allSecrets returns all the secrets to inject into act, from the secrets files and WithSecret.
+func (r *Runner) allSecrets() (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, fn := range r.secretsFiles {
+		fileSecrets, err := readDotEnvFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("read secrets file %q: %w", fn, err)
+		}
+		maps.Copy(secrets, fileSecrets)
+	}
+	maps.Copy(secrets, r.secrets)
+	return secrets, nil
+}
+
+// secretValues returns all the secret values the Runner knows about before running act:
+// the ones it injects into act and the ones provided via WithMaskedValues.
+func (r *Runner) secretValues() []string {
+	values := slices.Clone(r.maskedValues)
+	if r.gitHubToken != "" {
+		values = append(values, r.gitHubToken)
+	}
+	// Errors are reported when creating the act arguments, before we get here.
+	secrets, _ := r.allSecrets()
+	for _, value := range secrets {
+		values = append(values, value)
+	}
+	return values
+}
+
+// writeInputFiles writes the secrets, variables and env of the Runner to dotenv files
+// in a temporary directory, which is removed when the test ends.
+// Every run gets its own files, so parallel runs never share them.
+func (r *Runner) writeInputFiles() (secretsFile, varsFile, envFile string, err error) {
+	secrets, err := r.allSecrets()
+	if err != nil {
+		return "", "", "", err
+	}
+	dir := r.t.TempDir()
+	secretsFile = filepath.Join(dir, "secrets.env")
+	varsFile = filepath.Join(dir, "vars.env")
+	envFile = filepath.Join(dir, "env.env")
+	for fn, values := range map[string]map[string]string{
+		secretsFile: secrets,
+		varsFile:    r.variables,
+		envFile:     r.env,
+	} {
+		if err := writeDotEnvFile(fn, values); err != nil {
+			return "", "", "", err
+		}
+	}
+	return secretsFile, varsFile, envFile, nil
+}
+
+// writeDotEnvFile writes the given values to a dotenv file, as read by act.
+// Values are double-quoted and escaped, so they can contain newlines, quotes and dollar signs.
+func writeDotEnvFile(fn string, values map[string]string) error {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		b.WriteString(k + "=" + dotEnvQuote(values[k]) + "\n")
+	}
+	if err := os.WriteFile(fn, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("write dotenv file: %w", err)
+	}
+	return nil
+}
+
+// dotEnvEscaper escapes values for double-quoted dotenv values.
+var dotEnvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// dotEnvQuote returns the given value as a double-quoted dotenv value.
+func dotEnvQuote(value string) string {
+	return `"` + dotEnvEscaper.Replace(value) + `"`
+}
+
+// readDotEnvFile reads a dotenv file with KEY=VALUE lines.
+// Empty lines and lines starting with # are ignored, and an optional "export " prefix is stripped.
+// Values can be single-quoted (taken literally) or double-quoted (with \n, \r, \", \\ and \$ escapes).
+func readDotEnvFile(fn string) (map[string]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch {
+		case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+			v = v[1 : len(v)-1]
+		case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+			v, err = strconv.Unquote(strings.ReplaceAll(v, `\$`, "$"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %q: %w", k, err)
+			}
+		}
+		values[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
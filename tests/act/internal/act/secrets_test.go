@@ -0,0 +1,112 @@
+package act
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretMasker(t *testing.T) {
+	m := newSecretMasker("", "secret", "secret-longer")
+	require.Equal(t, "a *** and ***", m.mask("a secret and secret-longer"))
+
+	// Multi-line values are masked line by line
+	m.add("line1\nline2\n")
+	require.Equal(t, "*** ***", m.mask("line1 line2"))
+
+	// JSON-escaped values are masked as well
+	m.add(`quo"te`)
+	require.Equal(t, `{"arg":"***"}`, m.mask(`{"arg":"quo\"te"}`))
+
+	// Only the string values of JSON lines are masked
+	m = newSecretMasker("job", "true")
+	require.Equal(t, `{"jobID":"***","nested":["*** done"],"raw_output":true,"time":1.50}`, m.maskJSON([]byte(`{"jobID":"job","nested":["true done"],"raw_output":true,"time":1.50}`)))
+	require.Equal(t, `{"jobID":"build"}`, m.maskJSON([]byte(`{"jobID":"build"}`)), "lines without secrets are kept as-is")
+	require.Equal(t, "plain *** line", m.maskJSON([]byte("plain job line")))
+}
+
+func TestRunnerMasksSecrets(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "run.log")
+	line := func(l logLine) string {
+		l.JobID = "build"
+		l.StepID = []string{"hello"}
+		return jsonLogLine(t, l)
+	}
+	r := newTestRunner(t, &ScriptedExecutor{
+		Output: []string{
+			"plain text with test-token",
+			line(logLine{Command: "add-mask", Arg: "runtime-secret"}),
+			line(logLine{Command: "set-output", Name: "token", Arg: "runtime-secret"}),
+			line(logLine{Message: "printing runtime-secret"}),
+			line(logLine{Command: "group", Arg: "Group"}),
+			line(logLine{Message: "printing user-secret"}),
+			line(logLine{Command: "endgroup"}),
+		},
+	}, WithMaskedValues("user-secret"), WithRecording(fixture))
+	res, err := r.Run(newTestWorkflow(workflow.Step{ID: "hello", Run: "echo hello"}), NewPushEventPayload("main"))
+	require.NoError(t, err)
+
+	// Outputs keep the actual values, so tests can assert on them
+	token, ok := res.Outputs.Get("build", "hello", "token")
+	require.True(t, ok)
+	require.Equal(t, "runtime-secret", token)
+
+	// Log captures are masked
+	require.Equal(t, []string{"printing ***"}, res.LogGroups[0].Lines)
+	recording, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+	for _, secret := range []string{"test-token", "runtime-secret", "user-secret"} {
+		require.NotContains(t, string(recording), secret)
+	}
+	require.Contains(t, string(recording), "plain text with ***")
+}
+
+func TestRunnerInputs(t *testing.T) {
+	secretsFile := filepath.Join(t.TempDir(), ".secrets")
+	require.NoError(t, os.WriteFile(secretsFile, []byte(strings.Join([]string{
+		"# comment",
+		"FILE_SECRET='from file'",
+		`export OVERRIDDEN="from \"file\""`,
+	}, "\n")), 0o600))
+
+	r := newTestRunner(
+		t, &ScriptedExecutor{},
+		WithSecretsFile(secretsFile),
+		WithSecret("OVERRIDDEN", "from option"),
+		WithSecret("MULTILINE", "line1\nline2 $HOME \\ \"quoted\""),
+		WithVariable("SOME_VAR", "some value"),
+		WithEnv("SOME_ENV", "some env"),
+	)
+	args, port, err := r.args(EventKindPush, "", "workflow.yml", "payload.json")
+	require.NoError(t, err)
+	defer markPortAsFree(port)
+
+	for flag, exp := range map[string]map[string]string{
+		"--secret-file": {
+			"FILE_SECRET": "from file",
+			"OVERRIDDEN":  "from option",
+			"MULTILINE":   "line1\nline2 $HOME \\ \"quoted\"",
+		},
+		"--var-file": {"SOME_VAR": "some value"},
+		"--env-file": {"SOME_ENV": "some env"},
+	} {
+		i := slices.Index(args, flag)
+		require.NotEqual(t, -1, i, "flag %q should be present", flag)
+		values, err := readDotEnvFile(args[i+1])
+		require.NoError(t, err)
+		require.Equal(t, exp, values, "wrong values in %q", flag)
+	}
+
+	require.Subset(t, r.secretValues(), []string{"test-token", "from file", "from option"})
+
+	// Each run gets its own files
+	otherArgs, otherPort, err := r.args(EventKindPush, "", "workflow.yml", "payload.json")
+	require.NoError(t, err)
+	defer markPortAsFree(otherPort)
+	require.NotEqual(t, args[slices.Index(args, "--secret-file")+1], otherArgs[slices.Index(otherArgs, "--secret-file")+1])
+}
@@ -0,0 +1,269 @@
+package act
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// updateSnapshots is the flag that makes AssertSnapshot (re)write the snapshots, instead of comparing against them.
+var updateSnapshots = flag.Bool("update-snapshots", false, "update the RunResult snapshots in testdata")
+
+// getWorkflowRunIDJobID is the ID of the job added by NewTestingWorkflow to get the workflow run ID.
+const getWorkflowRunIDJobID = "get-workflow-run-id"
+
+// Snapshot is the part of a RunResult compared by AssertSnapshot, serialized to stable JSON.
+type Snapshot struct {
+	// Success indicates whether the workflow run was successful.
+	Success bool `json:"success"`
+
+	// Outputs are the outputs of the workflow run: job id -> step id -> output name -> value.
+	// The outputs of steps nested in composite actions are included under both their path and their top-level step.
+	// The outputs of the job added by NewTestingWorkflow to get the workflow run ID are not included.
+	Outputs map[string]map[string]map[string]string `json:"outputs"`
+
+	// MatrixOutputs are the outputs of the legs of the matrix jobs: job id -> legs, sorted by Matrix.Key.
+	// Like the outputs, the outputs of the job added by NewTestingWorkflow are not included.
+	MatrixOutputs map[string][]MatrixLegSnapshot `json:"matrix_outputs,omitempty"`
+
+	// Annotations are the annotations of the workflow run, sorted, since jobs can run concurrently.
+	// Like the outputs, the annotations of the job added by NewTestingWorkflow are not included.
+	Annotations []Annotation `json:"annotations"`
+
+	// Summary is the summary of the workflow run.
+	Summary []string `json:"summary"`
+}
+
+// MatrixLegSnapshot is the part of a Snapshot with the outputs of a single matrix job leg.
+type MatrixLegSnapshot struct {
+	// Matrix is the matrix of the leg.
+	Matrix Matrix `json:"matrix"`
+
+	// Outputs are the outputs of the leg: step id -> output name -> value.
+	Outputs map[string]map[string]string `json:"outputs"`
+}
+
+// Scrubber replaces the volatile values matching Regex in a Snapshot with <Name>,
+// so snapshots don't depend on values that change between runs.
+type Scrubber struct {
+	// Name is the name of the scrubbed value, written as <Name> in the snapshots.
+	Name string
+
+	// Regex matches the values to scrub.
+	Regex *regexp.Regexp
+}
+
+var (
+	// UUIDScrubber scrubs UUIDs, like the ones added to the jobs by AddUUIDToAllJobsRecursive.
+	UUIDScrubber = Scrubber{Name: "UUID", Regex: regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)}
+
+	// SHAScrubber scrubs full git commit SHAs.
+	SHAScrubber = Scrubber{Name: "SHA", Regex: regexp.MustCompile(`\b[0-9a-f]{40}\b`)}
+)
+
+// DefaultScrubbers are the scrubbers always applied to the snapshots.
+// The workflow run ID is always scrubbed as well, as <RUN_ID>.
+var DefaultScrubbers = []Scrubber{UUIDScrubber, SHAScrubber}
+
+// ValueScrubber returns a Scrubber that scrubs the given literal value, as <name>.
+func ValueScrubber(name string, value string) Scrubber {
+	return Scrubber{Name: name, Regex: regexp.MustCompile(regexp.QuoteMeta(value))}
+}
+
+// SnapshotOption configures a Snapshot.
+type SnapshotOption func(o *snapshotOptions)
+
+// snapshotOptions are the options of a Snapshot.
+type snapshotOptions struct {
+	scrubbers []Scrubber
+	jobs      []string
+	outputs   []string
+}
+
+// WithScrubbers adds the given scrubbers to DefaultScrubbers. They are applied in order, before the default ones,
+// so a known value is scrubbed by name rather than by kind (e.g.: ValueScrubber("HEAD_SHA", sha) rather than SHAScrubber)
+// and a wrong value can't hide behind a generic placeholder.
+func WithScrubbers(scrubbers ...Scrubber) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.scrubbers = append(o.scrubbers, scrubbers...)
+	}
+}
+
+// WithSnapshotJobs only includes the outputs and annotations of the jobs with the given IDs in the Snapshot.
+func WithSnapshotJobs(jobIDs ...string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.jobs = append(o.jobs, jobIDs...)
+	}
+}
+
+// WithSnapshotOutputs only includes the outputs with the given names in the Snapshot.
+// Steps and jobs left without outputs are not included.
+func WithSnapshotOutputs(names ...string) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.outputs = append(o.outputs, names...)
+	}
+}
+
+// scrub returns the given value with the volatile values replaced.
+func (o *snapshotOptions) scrub(value string) string {
+	for _, s := range o.scrubbers {
+		value = s.Regex.ReplaceAllLiteralString(value, "<"+s.Name+">")
+	}
+	return value
+}
+
+// scrubOutputs returns a copy of the included outputs (step id -> output name -> value) with the volatile values replaced.
+func (o *snapshotOptions) scrubOutputs(steps map[string]map[string]string) map[string]map[string]string {
+	scrubbed := make(map[string]map[string]string, len(steps))
+	for stepID, outputs := range steps {
+		for name, value := range outputs {
+			if len(o.outputs) > 0 && !slices.Contains(o.outputs, name) {
+				continue
+			}
+			if scrubbed[stepID] == nil {
+				scrubbed[stepID] = make(map[string]string, len(outputs))
+			}
+			scrubbed[stepID][name] = o.scrub(value)
+		}
+	}
+	return scrubbed
+}
+
+// includesJob returns true if the outputs and annotations of the given job are included in the Snapshot.
+func (o *snapshotOptions) includesJob(jobID string) bool {
+	return jobID != getWorkflowRunIDJobID && (len(o.jobs) == 0 || slices.Contains(o.jobs, jobID))
+}
+
+// Snapshot returns the Snapshot of the RunResult, with the volatile values scrubbed.
+func (r *RunResult) Snapshot(opts ...SnapshotOption) Snapshot {
+	var o snapshotOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.scrubbers = append(o.scrubbers, DefaultScrubbers...)
+	if runID, err := r.GetTestingWorkflowRunID(); err == nil && runID != "" {
+		// After the SHAs and UUIDs are scrubbed, so the run ID can't partially match them
+		o.scrubbers = append(o.scrubbers, Scrubber{Name: "RUN_ID", Regex: regexp.MustCompile(`\b` + regexp.QuoteMeta(runID) + `\b`)})
+	}
+
+	snapshot := Snapshot{
+		Success:     r.Success,
+		Outputs:     map[string]map[string]map[string]string{},
+		Annotations: make([]Annotation, 0, len(r.Annotations)),
+		Summary:     make([]string, 0, len(r.Summary)),
+	}
+	for jobID, steps := range r.Outputs.data {
+		if !o.includesJob(jobID) {
+			continue
+		}
+		if outputs := o.scrubOutputs(steps); len(outputs) > 0 {
+			snapshot.Outputs[jobID] = outputs
+		}
+	}
+	for jobID := range r.Outputs.matrix {
+		if !o.includesJob(jobID) {
+			continue
+		}
+		if snapshot.MatrixOutputs == nil {
+			snapshot.MatrixOutputs = map[string][]MatrixLegSnapshot{}
+		}
+		for _, matrix := range r.Outputs.Matrices(jobID) {
+			leg := r.Outputs.matrix[jobID][matrix.Key()]
+			snapshot.MatrixOutputs[jobID] = append(snapshot.MatrixOutputs[jobID], MatrixLegSnapshot{Matrix: leg.matrix, Outputs: o.scrubOutputs(leg.data)})
+		}
+	}
+	for _, a := range r.Annotations {
+		if !o.includesJob(a.JobID) {
+			continue
+		}
+		a.Title = o.scrub(a.Title)
+		a.Message = o.scrub(a.Message)
+		a.File = o.scrub(a.File)
+		snapshot.Annotations = append(snapshot.Annotations, a)
+	}
+	slices.SortFunc(snapshot.Annotations, func(a, b Annotation) int {
+		return cmp.Or(
+			cmp.Compare(a.JobID, b.JobID), cmp.Compare(a.Matrix.Key(), b.Matrix.Key()), cmp.Compare(a.StepID, b.StepID),
+			cmp.Compare(a.Level, b.Level), cmp.Compare(a.Title, b.Title), cmp.Compare(a.Message, b.Message),
+		)
+	})
+	for _, s := range r.Summary {
+		snapshot.Summary = append(snapshot.Summary, o.scrub(s))
+	}
+	return snapshot
+}
+
+// Marshal returns the stable, indented JSON of the Snapshot. Map keys are sorted.
+func (s Snapshot) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, fmt.Errorf("marshal snapshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// snapshotPathUnsafeChars matches the characters of a test name that are not safe in a file name.
+var snapshotPathUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9_/.-]+`)
+
+// SnapshotPath returns the path of the snapshot of the given test under testdata/snapshots, named after the test
+// (subtests are nested directories), with the characters that are not safe in a file name replaced with "_".
+func SnapshotPath(t testing.TB) string {
+	return filepath.Join("testdata", "snapshots", filepath.FromSlash(snapshotPathUnsafeChars.ReplaceAllString(t.Name(), "_"))+".json")
+}
+
+// AssertSnapshot asserts that the Snapshot of the given RunResult matches the one in the given file
+// (usually under testdata). On mismatch, the test fails with a unified diff of the snapshots.
+// With the -update-snapshots flag, the snapshot file is (re)written instead.
+func AssertSnapshot(t testing.TB, r *RunResult, path string, opts ...SnapshotOption) {
+	t.Helper()
+	assertSnapshot(t, r, path, *updateSnapshots, opts...)
+}
+
+// assertSnapshot is AssertSnapshot, with the value of the -update-snapshots flag passed in as update.
+func assertSnapshot(t testing.TB, r *RunResult, path string, update bool, opts ...SnapshotOption) {
+	t.Helper()
+	actual, err := r.Snapshot(opts...).Marshal()
+	if err != nil {
+		t.Fatalf("snapshot %q: %v", path, err)
+	}
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("create snapshot directory: %v", err)
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatalf("update snapshot: %v", err)
+		}
+		t.Logf("updated snapshot %q", path)
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot (run with -update-snapshots to create it): %v", err)
+	}
+	if bytes.Equal(expected, actual) {
+		return
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(expected)),
+		B:        difflib.SplitLines(string(actual)),
+		FromFile: path,
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		t.Fatalf("diff snapshot: %v", err)
+	}
+	t.Errorf("RunResult doesn't match snapshot %q (run with -update-snapshots to update it):\n%s", path, diff)
+}
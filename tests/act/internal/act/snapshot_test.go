@@ -0,0 +1,88 @@
+package act
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSnapshotTestRunResult returns a RunResult with volatile values in its outputs, annotations and summary.
+func newSnapshotTestRunResult() *RunResult {
+	r := newRunResult()
+	r.Success = true
+	r.Outputs.Set(getWorkflowRunIDJobID, "run-id", "run-id", "1234567")
+	r.Outputs.Set("setup", "vars", "environments", `["dev"]`)
+	r.Outputs.Set("setup", "vars", "plugin-version-suffix", "0123456789abcdef0123456789abcdef01234567")
+	r.Outputs.Set("setup", "vars", "artifact", "dist-artifacts-1234567")
+	r.Outputs.Set("build", "build", "id", "build-2b5c7f0e-8d5a-4c1e-9a3b-0d6f1e2a3b4c")
+	r.Annotations = Annotations{
+		{Level: AnnotationLevelWarning, Message: "deprecated input", JobID: "setup"},
+		{Level: AnnotationLevelError, Title: "Build", Message: "failed at 0123456789abcdef0123456789abcdef01234567", JobID: "build", File: "pkg/main.go", Line: 3},
+		{Level: AnnotationLevelDebug, Message: "run id", JobID: getWorkflowRunIDJobID},
+	}
+	r.Summary = []string{"# Deployment\n\nRun 1234567 deployed to dev <br>\n"}
+	return &r
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Run("scrubbers", func(t *testing.T) {
+		snapshot := newSnapshotTestRunResult().Snapshot(WithScrubbers(ValueScrubber("ENV", "dev")))
+		require.NotContains(t, snapshot.Outputs, getWorkflowRunIDJobID)
+		require.Equal(t, map[string]string{
+			"environments":          `["<ENV>"]`,
+			"plugin-version-suffix": "<SHA>",
+			"artifact":              "dist-artifacts-<RUN_ID>",
+		}, snapshot.Outputs["setup"]["vars"])
+		require.Equal(t, "build-<UUID>", snapshot.Outputs["build"]["build"]["id"])
+		require.Equal(t, []Annotation{
+			{Level: AnnotationLevelError, Title: "Build", Message: "failed at <SHA>", JobID: "build", File: "pkg/main.go", Line: 3},
+			{Level: AnnotationLevelWarning, Message: "deprecated input", JobID: "setup"},
+		}, snapshot.Annotations, "annotations should be sorted")
+		require.Equal(t, []string{"# Deployment\n\nRun <RUN_ID> deployed to <ENV> <br>\n"}, snapshot.Summary)
+	})
+
+	t.Run("caller scrubbers first", func(t *testing.T) {
+		// A known SHA is scrubbed by name, so a different SHA doesn't match the snapshot
+		scrubber := WithScrubbers(ValueScrubber("HEAD_SHA", "0123456789abcdef0123456789abcdef01234567"))
+		snapshot := newSnapshotTestRunResult().Snapshot(scrubber)
+		require.Equal(t, "<HEAD_SHA>", snapshot.Outputs["setup"]["vars"]["plugin-version-suffix"])
+
+		r := newSnapshotTestRunResult()
+		r.Outputs.Set("setup", "vars", "plugin-version-suffix", "fedcba9876543210fedcba9876543210fedcba98")
+		require.Equal(t, "<SHA>", r.Snapshot(scrubber).Outputs["setup"]["vars"]["plugin-version-suffix"])
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		snapshot := newSnapshotTestRunResult().Snapshot(WithSnapshotJobs("build"))
+		require.Len(t, snapshot.Outputs, 1)
+		require.Contains(t, snapshot.Outputs, "build")
+		require.Len(t, snapshot.Annotations, 1)
+		require.Equal(t, "build", snapshot.Annotations[0].JobID)
+	})
+
+	t.Run("outputs", func(t *testing.T) {
+		snapshot := newSnapshotTestRunResult().Snapshot(WithSnapshotOutputs("environments", "artifact"))
+		require.Equal(t, map[string]map[string]map[string]string{
+			"setup": {"vars": {"environments": `["dev"]`, "artifact": "dist-artifacts-<RUN_ID>"}},
+		}, snapshot.Outputs, "jobs without the outputs should not be included")
+	})
+
+	t.Run("path", func(t *testing.T) {
+		require.Equal(t, filepath.Join("testdata", "snapshots", "TestSnapshot", "path.json"), SnapshotPath(t))
+		t.Run("auto-cd:push there's a PR", func(t *testing.T) {
+			require.Equal(t, filepath.Join("testdata", "snapshots", "TestSnapshot", "path", "auto-cd_push_there_s_a_PR.json"), SnapshotPath(t))
+		})
+	})
+
+	t.Run("assert", func(t *testing.T) {
+		AssertSnapshot(t, newSnapshotTestRunResult(), filepath.Join("testdata", "snapshots", "run_result.json"))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snapshots", "run_result.json")
+		assertSnapshot(t, newSnapshotTestRunResult(), path, true)
+		require.FileExists(t, path)
+		assertSnapshot(t, newSnapshotTestRunResult(), path, false)
+	})
+}
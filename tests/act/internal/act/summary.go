@@ -0,0 +1,324 @@
+package act
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// StepSummary is the content written by a step to GITHUB_STEP_SUMMARY, parsed into its markdown blocks.
+// Inline formatting (emphasis, code spans, links) is stripped from the text of the blocks,
+// so tests can match on plain values. The original markdown is kept in Markdown.
+type StepSummary struct {
+	// JobID is the ID of the job that wrote the summary.
+	JobID string
+
+	// StepID is the ID of the top-level step that wrote the summary.
+	StepID string
+
+	// Markdown is the raw markdown content of the summary.
+	Markdown string
+
+	// Headings contains the ATX headings ("# Title") of the summary, in order.
+	Headings []Heading
+
+	// Paragraphs contains the text of the paragraphs of the summary, in order.
+	// The lines of a paragraph are joined with a space.
+	Paragraphs []string
+
+	// Tables contains the GFM pipe tables of the summary, in order.
+	Tables []Table
+
+	// Lists contains the bullet and ordered lists of the summary, in order.
+	Lists []List
+
+	// CodeBlocks contains the fenced code blocks of the summary, in order.
+	CodeBlocks []CodeBlock
+}
+
+// Heading is a heading in a step summary.
+type Heading struct {
+	// Level is the level of the heading, from 1 ("#") to 6 ("######").
+	Level int
+
+	// Text is the text of the heading.
+	Text string
+}
+
+// Table is a GFM pipe table in a step summary.
+type Table struct {
+	// Section is the text of the heading preceding the table, if any.
+	Section string
+
+	// Header contains the cells of the header row.
+	Header []string
+
+	// Rows contains the cells of the body rows.
+	Rows [][]string
+}
+
+// Column returns the cells of the column with the given header, or false if the table has no such column.
+// Rows with fewer cells than the header have an empty cell in the column.
+func (t Table) Column(header string) ([]string, bool) {
+	i := slices.Index(t.Header, header)
+	if i < 0 {
+		return nil, false
+	}
+	column := make([]string, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		var cell string
+		if i < len(row) {
+			cell = row[i]
+		}
+		column = append(column, cell)
+	}
+	return column, true
+}
+
+// List is a bullet or ordered list in a step summary.
+type List struct {
+	// Section is the text of the heading preceding the list, if any.
+	Section string
+
+	// Ordered indicates whether the list is an ordered ("1.") list.
+	Ordered bool
+
+	// Items contains the text of the list items, in order.
+	// Items of nested lists are flattened into the parent list.
+	Items []string
+}
+
+// CodeBlock is a fenced code block in a step summary.
+type CodeBlock struct {
+	// Section is the text of the heading preceding the code block, if any.
+	Section string
+
+	// Language is the info string of the code block (e.g.: "json"), if any.
+	Language string
+
+	// Code is the content of the code block, without the fences.
+	Code string
+}
+
+// Table returns the first table in the section with the given heading text, or false if there is none.
+func (s StepSummary) Table(section string) (Table, bool) {
+	for _, table := range s.Tables {
+		if table.Section == section {
+			return table, true
+		}
+	}
+	return Table{}, false
+}
+
+// List returns the first list in the section with the given heading text, or false if there is none.
+func (s StepSummary) List(section string) (List, bool) {
+	for _, list := range s.Lists {
+		if list.Section == section {
+			return list, true
+		}
+	}
+	return List{}, false
+}
+
+// CodeBlock returns the first code block in the section with the given heading text, or false if there is none.
+func (s StepSummary) CodeBlock(section string) (CodeBlock, bool) {
+	for _, block := range s.CodeBlocks {
+		if block.Section == section {
+			return block, true
+		}
+	}
+	return CodeBlock{}, false
+}
+
+// StepSummary returns the summary written by the given step of the given job, or false if the step wrote none.
+func (r *RunResult) StepSummary(jobID, stepID string) (StepSummary, bool) {
+	for _, summary := range r.StepSummaries {
+		if summary.JobID == jobID && summary.StepID == stepID {
+			return summary, true
+		}
+	}
+	return StepSummary{}, false
+}
+
+var (
+	// summaryHeadingRegex matches an ATX heading, capturing the hashes and the text without the optional closing hashes.
+	summaryHeadingRegex = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+	// summaryFenceRegex matches the opening fence of a code block, capturing the fence and the info string.
+	summaryFenceRegex = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`]*)$")
+
+	// summaryListItemRegex matches a list item, capturing the indentation, the marker and the text.
+	summaryListItemRegex = regexp.MustCompile(`^([ \t]*)([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+
+	// summaryTableDelimiterRegex matches the delimiter row of a table, e.g. "| --- | :-: |".
+	summaryTableDelimiterRegex = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+
+	// summaryInlineRegexes match the inline formatting stripped from the text of the blocks, in order.
+	// The first group of each match is kept.
+	summaryInlineRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`),
+		regexp.MustCompile("`+([^`]*)`+"),
+		regexp.MustCompile(`\*\*(.+?)\*\*`),
+		regexp.MustCompile(`__(.+?)__`),
+		regexp.MustCompile(`~~(.+?)~~`),
+		regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`),
+	}
+)
+
+// ParseSummary parses the given GITHUB_STEP_SUMMARY markdown into its headings, paragraphs, tables, lists and code blocks.
+// It supports the subset of GitHub Flavored Markdown commonly used in step summaries. Unsupported
+// constructs (e.g.: HTML, block quotes) are treated as paragraphs.
+func ParseSummary(markdown string) StepSummary {
+	p := summaryParser{summary: StepSummary{Markdown: markdown}}
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			p.flush()
+			continue
+		}
+		if m := summaryFenceRegex.FindStringSubmatch(line); m != nil {
+			p.flush()
+			i = p.codeBlock(lines, i, m[1], m[2])
+			continue
+		}
+		if m := summaryHeadingRegex.FindStringSubmatch(line); m != nil {
+			p.flush()
+			heading := Heading{Level: len(m[1]), Text: plainSummaryText(m[2])}
+			p.summary.Headings = append(p.summary.Headings, heading)
+			p.section = heading.Text
+			continue
+		}
+		if strings.Contains(line, "|") && i+1 < len(lines) && summaryTableDelimiterRegex.MatchString(lines[i+1]) {
+			p.flush()
+			i = p.table(lines, i)
+			continue
+		}
+		if m := summaryListItemRegex.FindStringSubmatch(line); m != nil && (p.list != nil || m[3] != "") {
+			p.listItem(m[2], m[3])
+			continue
+		}
+		if p.list != nil && (line[0] == ' ' || line[0] == '\t') {
+			// Continuation of the last list item
+			last := len(p.list.Items) - 1
+			p.list.Items[last] = strings.TrimSpace(p.list.Items[last] + " " + plainSummaryText(line))
+			continue
+		}
+		p.flushList()
+		p.paragraph = append(p.paragraph, strings.TrimSpace(line))
+	}
+	p.flush()
+	return p.summary
+}
+
+// summaryParser keeps the state of ParseSummary.
+type summaryParser struct {
+	summary StepSummary
+
+	// section is the text of the last heading.
+	section string
+
+	// paragraph contains the lines of the paragraph being parsed, if any.
+	paragraph []string
+
+	// list is the list being parsed, if any.
+	list *List
+}
+
+// flush ends the paragraph and the list being parsed, if any.
+func (p *summaryParser) flush() {
+	p.flushList()
+	if len(p.paragraph) > 0 {
+		p.summary.Paragraphs = append(p.summary.Paragraphs, plainSummaryText(strings.Join(p.paragraph, " ")))
+		p.paragraph = nil
+	}
+}
+
+// flushList ends the list being parsed, if any.
+func (p *summaryParser) flushList() {
+	if p.list != nil {
+		p.summary.Lists = append(p.summary.Lists, *p.list)
+		p.list = nil
+	}
+}
+
+// listItem adds an item with the given marker and text to the list being parsed, starting a new list if needed.
+// A list item interrupts the paragraph being parsed.
+func (p *summaryParser) listItem(marker, text string) {
+	if p.list == nil {
+		if len(p.paragraph) > 0 {
+			p.flush()
+		}
+		p.list = &List{Section: p.section, Ordered: !strings.ContainsAny(marker, "-*+")}
+	}
+	p.list.Items = append(p.list.Items, plainSummaryText(text))
+}
+
+// codeBlock parses the code block opened by the given fence at lines[start],
+// and returns the index of its closing fence (or of the last line, if the block is not closed).
+func (p *summaryParser) codeBlock(lines []string, start int, fence, info string) int {
+	block := CodeBlock{Section: p.section}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		block.Language = fields[0]
+	}
+	var code []string
+	end := len(lines) - 1
+	for i := start + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			end = i
+			break
+		}
+		code = append(code, lines[i])
+	}
+	block.Code = strings.Join(code, "\n")
+	p.summary.CodeBlocks = append(p.summary.CodeBlocks, block)
+	return end
+}
+
+// table parses the table whose header row is lines[start], and returns the index of its last row.
+func (p *summaryParser) table(lines []string, start int) int {
+	table := Table{Section: p.section, Header: splitTableRow(lines[start]), Rows: [][]string{}}
+	end := start + 1
+	for i := start + 2; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" || !strings.Contains(lines[i], "|") {
+			break
+		}
+		table.Rows = append(table.Rows, splitTableRow(lines[i]))
+		end = i
+	}
+	p.summary.Tables = append(p.summary.Tables, table)
+	return end
+}
+
+// splitTableRow splits the given table row into its cells. Escaped pipes ("\|") are kept in the cells.
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = strings.TrimSuffix(row, "|")
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, plainSummaryText(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, plainSummaryText(cell.String()))
+}
+
+// plainSummaryText strips the inline markdown formatting from the given text and trims it.
+func plainSummaryText(text string) string {
+	for _, re := range summaryInlineRegexes {
+		text = re.ReplaceAllString(text, "$1")
+	}
+	return strings.TrimSpace(text)
+}
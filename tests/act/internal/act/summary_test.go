@@ -0,0 +1,87 @@
+package act
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSummary(t *testing.T) {
+	t.Run("blocks", func(t *testing.T) {
+		summary := ParseSummary("# 🚀 Deployment #\n" +
+			"\n" +
+			"The plugin has been **deployed** to [Grafana Cloud](https://grafana.com).\n" +
+			"Follow the rollout below.\n" +
+			"\n" +
+			"## Environments\n" +
+			"\n" +
+			"| Environment | Status | Notes |\n" +
+			"| :--- | :-: | --- |\n" +
+			"| `dev` | ✅ | a \\| b |\n" +
+			"| ops | ✅ |\n" +
+			"\n" +
+			"## Steps\n" +
+			"1. Build\n" +
+			"2. Publish\n" +
+			"   to the catalog\n" +
+			"   - nested\n" +
+			"\n" +
+			"```json\n" +
+			"{\"id\": \"grafana-plugin\"}\n" +
+			"\n" +
+			"# not a heading\n" +
+			"```\n")
+
+		require.Equal(t, []Heading{
+			{Level: 1, Text: "🚀 Deployment"},
+			{Level: 2, Text: "Environments"},
+			{Level: 2, Text: "Steps"},
+		}, summary.Headings)
+		require.Equal(t, []string{"The plugin has been deployed to Grafana Cloud. Follow the rollout below."}, summary.Paragraphs)
+		require.Equal(t, []Table{{
+			Section: "Environments",
+			Header:  []string{"Environment", "Status", "Notes"},
+			Rows:    [][]string{{"dev", "✅", "a | b"}, {"ops", "✅"}},
+		}}, summary.Tables)
+		require.Equal(t, []List{{
+			Section: "Steps",
+			Ordered: true,
+			Items:   []string{"Build", "Publish to the catalog", "nested"},
+		}}, summary.Lists)
+		require.Equal(t, []CodeBlock{{
+			Section:  "Steps",
+			Language: "json",
+			Code:     "{\"id\": \"grafana-plugin\"}\n\n# not a heading",
+		}}, summary.CodeBlocks)
+
+		table, ok := summary.Table("Environments")
+		require.True(t, ok)
+		environments, ok := table.Column("Environment")
+		require.True(t, ok)
+		require.Equal(t, []string{"dev", "ops"}, environments)
+		notes, ok := table.Column("Notes")
+		require.True(t, ok)
+		require.Equal(t, []string{"a | b", ""}, notes)
+		_, ok = table.Column("Region")
+		require.False(t, ok)
+		_, ok = summary.Table("Steps")
+		require.False(t, ok)
+	})
+
+	t.Run("list interrupts paragraph", func(t *testing.T) {
+		summary := ParseSummary("A deployment has been triggered.\n" +
+			"- Plugin Version: `1.0.0`\n" +
+			"* Environment(s): `dev,ops`\n" +
+			"\n" +
+			"**👉 You can follow the deployment [here](https://example.com)**\n")
+		require.Equal(t, []string{"A deployment has been triggered.", "👉 You can follow the deployment here"}, summary.Paragraphs)
+		require.Equal(t, []List{{Items: []string{"Plugin Version: 1.0.0", "Environment(s): dev,ops"}}}, summary.Lists)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		summary := ParseSummary("")
+		require.Empty(t, summary.Headings)
+		require.Empty(t, summary.Paragraphs)
+		require.Empty(t, summary.Tables)
+	})
+}
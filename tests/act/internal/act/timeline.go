@@ -0,0 +1,239 @@
+package act
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
+)
+
+// Conclusion is the conclusion of a job or step, as reported by act.
+type Conclusion string
+
+// Conclusion enum values
+const (
+	ConclusionSuccess   Conclusion = "success"
+	ConclusionFailure   Conclusion = "failure"
+	ConclusionSkipped   Conclusion = "skipped"
+	ConclusionCancelled Conclusion = "cancelled"
+)
+
+// JobResult contains the timeline and the conclusion of a single job of a workflow run.
+// Matrix legs and jobs with the same ID in different (child) workflows share the same JobResult:
+// it spans from the first log line of any of them to the last one, and it fails if any of them failed.
+// The job is running until all its legs that started reported their result.
+type JobResult struct {
+	// ID is the job ID, as defined in the workflow.
+	ID string
+
+	// Name is the name of the job, without the testing workflow UUID suffix.
+	Name string
+
+	// StartedAt is the time of the first log line of the job.
+	StartedAt time.Time
+
+	// FinishedAt is the time when act reported the job result.
+	// It is zero if the job did not finish.
+	FinishedAt time.Time
+
+	// Conclusion is the conclusion of the job.
+	// It is empty if the job is still running.
+	Conclusion Conclusion
+
+	// Steps contains the top-level steps of the job, in the order they were declared.
+	// Steps of composite actions are grouped under their top-level step.
+	Steps []*StepResult
+
+	// legs is a map of matrix key (see Matrix.Key) -> conclusion of the matrix leg, empty while the leg is running.
+	// Jobs without a matrix have a single leg with an empty key.
+	legs map[string]Conclusion
+}
+
+// Duration returns the duration of the job.
+// It is zero if the job did not start or did not finish.
+func (j *JobResult) Duration() time.Duration {
+	if j.StartedAt.IsZero() || j.FinishedAt.IsZero() {
+		return 0
+	}
+	return j.FinishedAt.Sub(j.StartedAt)
+}
+
+// Step returns the step with the given ID, or nil if the step is not found.
+// Steps without an explicit ID in the workflow are identified by their index (e.g.: "0", "1", ...).
+func (j *JobResult) Step(id string) *StepResult {
+	for _, s := range j.Steps {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// StepResult contains the timeline and the conclusion of a single step of a job.
+type StepResult struct {
+	// ID is the step ID. Steps without an explicit ID are identified by their index (e.g.: "0", "1", ...).
+	ID string
+
+	// Name is the name of the step, as displayed by act.
+	Name string
+
+	// StartedAt is the time of the first log line of the step, in any stage (pre, main, post).
+	StartedAt time.Time
+
+	// FinishedAt is the time when act reported the result of the last stage of the step.
+	// It is zero if the step did not finish.
+	FinishedAt time.Time
+
+	// Conclusion is the conclusion of the step.
+	// It is the result of its main stage, unless any other stage failed.
+	// It is empty if the step is still running.
+	Conclusion Conclusion
+}
+
+// Duration returns the duration of the step.
+// It is zero if the step did not start or did not finish.
+func (s *StepResult) Duration() time.Duration {
+	if s.StartedAt.IsZero() || s.FinishedAt.IsZero() {
+		return 0
+	}
+	return s.FinishedAt.Sub(s.StartedAt)
+}
+
+// trackJob updates the timeline of the job (and step) that produced the given log line.
+// A job is considered running from its first log line until act reports its result.
+func (r *RunResult) trackJob(data logLine) {
+	if data.JobID == "" {
+		return
+	}
+	job, ok := r.Jobs[data.JobID]
+	if !ok {
+		job = &JobResult{
+			ID:        data.JobID,
+			Name:      logUUIDRegex.ReplaceAllString(data.Job, ""),
+			StartedAt: data.Time,
+			legs:      map[string]Conclusion{},
+		}
+		r.Jobs[data.JobID] = job
+	}
+	leg := data.Matrix.Key()
+	if _, ok := job.legs[leg]; !ok {
+		// A leg that starts after the others finished makes the job running again
+		job.legs[leg] = ""
+		job.Conclusion = ""
+	}
+	if data.JobResult != "" {
+		job.FinishedAt = data.Time
+		job.legs[leg] = Conclusion(data.JobResult)
+		job.Conclusion = job.legsConclusion(Conclusion(data.JobResult))
+	}
+	if len(data.StepID) == 0 {
+		return
+	}
+
+	// Steps of composite actions are grouped under their top-level step
+	step := job.Step(data.StepID[0])
+	if step == nil {
+		step = &StepResult{ID: data.StepID[0], Name: data.Step, StartedAt: data.Time}
+		job.Steps = append(job.Steps, step)
+	}
+	if data.StepResult == "" || len(data.StepID) > 1 {
+		return
+	}
+	step.FinishedAt = data.Time
+	switch {
+	case Conclusion(data.StepResult) == ConclusionFailure:
+		step.Conclusion = ConclusionFailure
+	case step.Conclusion == "" || (data.Stage == "Main" && step.Conclusion != ConclusionFailure):
+		step.Conclusion = Conclusion(data.StepResult)
+	}
+}
+
+// legsConclusion returns the conclusion of the job after one of its legs finished with the given conclusion:
+// empty if any leg is still running, failure if any leg failed, otherwise the given conclusion.
+func (j *JobResult) legsConclusion(last Conclusion) Conclusion {
+	conclusion := last
+	for _, legConclusion := range j.legs {
+		switch legConclusion {
+		case "":
+			return ""
+		case ConclusionFailure:
+			conclusion = ConclusionFailure
+		}
+	}
+	return conclusion
+}
+
+// runningJobs returns the sorted IDs of the jobs that started but did not finish yet.
+func (r *RunResult) runningJobs() []string {
+	var running []string
+	for jobID, job := range r.Jobs {
+		if job.Conclusion == "" {
+			running = append(running, jobID)
+		}
+	}
+	slices.Sort(running)
+	return running
+}
+
+// markCancelled marks all the jobs and steps that did not finish as cancelled.
+func (r *RunResult) markCancelled() {
+	for _, job := range r.Jobs {
+		if job.Conclusion != "" {
+			continue
+		}
+		job.Conclusion = ConclusionCancelled
+		for _, step := range job.Steps {
+			if step.Conclusion == "" {
+				step.Conclusion = ConclusionCancelled
+			}
+		}
+	}
+}
+
+// markSkipped adds skipped jobs and steps to the timeline, by comparing it with the given workflow and its children.
+// act only reports skipped jobs and steps in debug logs, so jobs and steps declared in the workflow that never produced
+// any output are considered skipped. Jobs calling reusable workflows are ignored, since they don't run on their own.
+// Steps of jobs that ran are re-ordered following the declaration order.
+func (r *RunResult) markSkipped(wf workflow.Workflow) {
+	jobs := map[string]*workflow.Job{}
+	for _, wfJobs := range allWorkflowJobs(wf) {
+		for id, job := range wfJobs {
+			if _, ok := jobs[id]; !ok && job.Uses == "" {
+				jobs[id] = job
+			}
+		}
+	}
+
+	for id, job := range jobs {
+		jobResult, ok := r.Jobs[id]
+		if !ok {
+			jobResult = &JobResult{
+				ID:         id,
+				Name:       logUUIDRegex.ReplaceAllString(job.Name, ""),
+				Conclusion: ConclusionSkipped,
+			}
+			r.Jobs[id] = jobResult
+		}
+		steps := make([]*StepResult, 0, max(len(job.Steps), len(jobResult.Steps)))
+		for i, step := range job.Steps {
+			// act identifies steps without an explicit id by their index
+			stepID := step.ID
+			if stepID == "" {
+				stepID = strconv.Itoa(i)
+			}
+			stepResult := jobResult.Step(stepID)
+			if stepResult == nil {
+				stepResult = &StepResult{ID: stepID, Name: step.Name, Conclusion: ConclusionSkipped}
+			}
+			steps = append(steps, stepResult)
+		}
+		// Keep any step that was not declared (should not happen, but better safe than sorry)
+		for _, stepResult := range jobResult.Steps {
+			if !slices.Contains(steps, stepResult) {
+				steps = append(steps, stepResult)
+			}
+		}
+		jobResult.Steps = steps
+	}
+}
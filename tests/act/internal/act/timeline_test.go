@@ -0,0 +1,82 @@
+package act
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackJob(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	t.Run("steps", func(t *testing.T) {
+		res := newRunResult()
+		for _, l := range []logLine{
+			{Time: at(0), JobID: "build", Job: "Build", Message: "Set up job"},
+			{Time: at(1), JobID: "build", Job: "Build", Stage: "Pre", Step: "Checkout", StepID: []string{"checkout"}, StepResult: "success"},
+			{Time: at(2), JobID: "build", Job: "Build", Stage: "Main", Step: "Hello", StepID: []string{"hello"}, Message: "Run Main Hello"},
+			{Time: at(3), JobID: "build", Job: "Build", Stage: "Main", Step: "Hello", StepID: []string{"hello", "nested"}, StepResult: "failure"},
+			{Time: at(4), JobID: "build", Job: "Build", Stage: "Main", Step: "Hello", StepID: []string{"hello"}, StepResult: "success"},
+			{Time: at(5), JobID: "build", Job: "Build", Stage: "Main", Step: "Checkout", StepID: []string{"checkout"}, StepResult: "success"},
+			{Time: at(6), JobID: "build", Job: "Build", Stage: "Post", Step: "Checkout", StepID: []string{"checkout"}, StepResult: "failure"},
+			{Time: at(7), JobID: "build", Job: "Build", JobResult: "failure"},
+		} {
+			res.trackJob(l)
+		}
+
+		job := res.Jobs["build"]
+		require.Equal(t, "Build", job.Name)
+		require.Equal(t, ConclusionFailure, job.Conclusion)
+		require.Equal(t, 7*time.Second, job.Duration())
+		require.Len(t, job.Steps, 2)
+		require.Equal(t, ConclusionSuccess, job.Step("hello").Conclusion, "nested steps should not change the conclusion")
+		require.Equal(t, 2*time.Second, job.Step("hello").Duration())
+		require.Equal(t, ConclusionFailure, job.Step("checkout").Conclusion, "a failed post stage should fail the step")
+		require.Empty(t, res.runningJobs())
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		res := newRunResult()
+		res.trackJob(logLine{Time: at(0), JobID: "build", Step: "Hello", StepID: []string{"hello"}, StepResult: "success"})
+		res.trackJob(logLine{Time: at(1), JobID: "build", Step: "Deploy", StepID: []string{"deploy"}, Message: "Deploying"})
+		res.markCancelled()
+		require.Equal(t, ConclusionCancelled, res.Jobs["build"].Conclusion)
+		require.Equal(t, ConclusionSuccess, res.Jobs["build"].Step("hello").Conclusion, "finished steps should keep their conclusion")
+		require.Equal(t, ConclusionCancelled, res.Jobs["build"].Step("deploy").Conclusion)
+	})
+
+	t.Run("matrix legs", func(t *testing.T) {
+		dev, prod := Matrix{"env": "dev"}, Matrix{"env": "prod"}
+		res := newRunResult()
+		res.trackJob(logLine{Time: at(0), JobID: "deploy", Matrix: dev, Message: "Set up job"})
+		res.trackJob(logLine{Time: at(1), JobID: "deploy", Matrix: prod, Message: "Set up job"})
+		res.trackJob(logLine{Time: at(2), JobID: "deploy", Matrix: dev, JobResult: "success"})
+		require.Empty(t, res.Jobs["deploy"].Conclusion, "the job should run until all its legs finished")
+		require.Equal(t, []string{"deploy"}, res.runningJobs())
+
+		res.trackJob(logLine{Time: at(3), JobID: "deploy", Matrix: prod, JobResult: "success"})
+		require.Equal(t, ConclusionSuccess, res.Jobs["deploy"].Conclusion)
+		require.Equal(t, 3*time.Second, res.Jobs["deploy"].Duration())
+		require.Empty(t, res.runningJobs())
+	})
+
+	t.Run("failed matrix leg", func(t *testing.T) {
+		res := newRunResult()
+		res.trackJob(logLine{Time: at(0), JobID: "deploy", Matrix: Matrix{"env": "dev"}, JobResult: "failure"})
+		res.trackJob(logLine{Time: at(1), JobID: "deploy", Matrix: Matrix{"env": "prod"}, JobResult: "success"})
+		require.Equal(t, ConclusionFailure, res.Jobs["deploy"].Conclusion, "a failed leg should fail the job")
+	})
+
+	t.Run("cancelled matrix leg", func(t *testing.T) {
+		res := newRunResult()
+		res.trackJob(logLine{Time: at(0), JobID: "deploy", Matrix: Matrix{"env": "dev"}, JobResult: "success"})
+		res.trackJob(logLine{Time: at(1), JobID: "deploy", Matrix: Matrix{"env": "prod"}, Step: "Deploy", StepID: []string{"deploy"}, Message: "Deploying"})
+		res.markCancelled()
+		require.Equal(t, ConclusionCancelled, res.Jobs["deploy"].Conclusion)
+		require.Equal(t, ConclusionCancelled, res.Jobs["deploy"].Step("deploy").Conclusion)
+	})
+}
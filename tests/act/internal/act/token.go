@@ -0,0 +1,112 @@
+package act
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// TokenProvider provides the GitHub token passed to act as the GITHUB_TOKEN secret.
+// The token is used by act to clone external actions and by workflows that call the GitHub API.
+type TokenProvider interface {
+	// Token returns the GitHub token.
+	// An empty token means that the Runner runs in anonymous (offline) mode.
+	Token() (string, error)
+}
+
+// EnvTokenProvider is a TokenProvider that reads the token from an environment variable.
+type EnvTokenProvider struct {
+	// Name is the name of the environment variable. If empty, GITHUB_TOKEN is used.
+	Name string
+}
+
+// Token returns the value of the environment variable.
+// It returns an error if the environment variable is not set or empty.
+func (p EnvTokenProvider) Token() (string, error) {
+	name := p.Name
+	if name == "" {
+		name = "GITHUB_TOKEN"
+	}
+	token := os.Getenv(name)
+	if token == "" {
+		return "", fmt.Errorf("environment variable %q is not set", name)
+	}
+	return token, nil
+}
+
+// GHCLITokenProvider is a TokenProvider that gets the token from the gh CLI (`gh auth token`).
+type GHCLITokenProvider struct{}
+
+// Token returns the token of the user logged in via the gh CLI.
+func (GHCLITokenProvider) Token() (string, error) {
+	if !checkExecutable("gh") {
+		return "", errors.New(`"gh" executable not found`)
+	}
+	output, err := exec.Command("gh", "auth", "token").Output()
+	if err != nil {
+		return "", fmt.Errorf("exec 'gh auth token': %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// StaticTokenProvider is a TokenProvider that always returns the same token.
+type StaticTokenProvider string
+
+// Token returns the static token.
+func (p StaticTokenProvider) Token() (string, error) {
+	if p == "" {
+		return "", errors.New("empty static token")
+	}
+	return string(p), nil
+}
+
+// AnonymousTokenProvider is a TokenProvider for running without GitHub credentials and network access.
+// act runs in offline mode: external `uses:` references are resolved from the actions cache,
+// which is populated from the pre-warmed TemplateActionsCachePath, and Docker images are not pulled.
+// All the actions and images used by the workflow must have been fetched beforehand (cache warmup).
+type AnonymousTokenProvider struct{}
+
+// Token returns an empty token.
+func (AnonymousTokenProvider) Token() (string, error) {
+	return "", nil
+}
+
+// DefaultTokenProvider is the TokenProvider used by NewRunner if none is set with WithTokenProvider.
+// It reads the token from the GITHUB_TOKEN environment variable (GitHub Actions) or from the gh CLI (local).
+var DefaultTokenProvider TokenProvider = chainTokenProvider{EnvTokenProvider{}, GHCLITokenProvider{}}
+
+// chainTokenProvider is a TokenProvider that returns the token from the first provider that succeeds.
+type chainTokenProvider []TokenProvider
+
+// Token returns the token from the first provider that succeeds.
+// If all providers fail, it returns the error of the last one.
+func (c chainTokenProvider) Token() (token string, err error) {
+	for _, p := range c {
+		token, err = p.Token()
+		if err == nil {
+			return token, nil
+		}
+	}
+	return "", err
+}
+
+// WithTokenProvider sets the TokenProvider used to get the GitHub token.
+// By default, the Runner uses DefaultTokenProvider.
+// Use AnonymousTokenProvider to run without GitHub credentials and network access.
+func WithTokenProvider(provider TokenProvider) RunnerOption {
+	return func(r *Runner) {
+		r.tokenProvider = provider
+	}
+}
+
+// Static checks
+
+var (
+	_ TokenProvider = EnvTokenProvider{}
+	_ TokenProvider = GHCLITokenProvider{}
+	_ TokenProvider = StaticTokenProvider("")
+	_ TokenProvider = AnonymousTokenProvider{}
+	_ TokenProvider = chainTokenProvider{}
+)
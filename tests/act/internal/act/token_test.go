@@ -0,0 +1,61 @@
+package act
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenProviders(t *testing.T) {
+	t.Setenv("SOME_TOKEN", "env-token")
+	t.Setenv("EMPTY_TOKEN", "")
+
+	token, err := EnvTokenProvider{Name: "SOME_TOKEN"}.Token()
+	require.NoError(t, err)
+	require.Equal(t, "env-token", token)
+
+	_, err = EnvTokenProvider{Name: "EMPTY_TOKEN"}.Token()
+	require.Error(t, err)
+
+	token, err = StaticTokenProvider("static-token").Token()
+	require.NoError(t, err)
+	require.Equal(t, "static-token", token)
+
+	token, err = AnonymousTokenProvider{}.Token()
+	require.NoError(t, err)
+	require.Empty(t, token)
+
+	// The first provider that succeeds wins
+	token, err = chainTokenProvider{EnvTokenProvider{Name: "EMPTY_TOKEN"}, EnvTokenProvider{Name: "SOME_TOKEN"}}.Token()
+	require.NoError(t, err)
+	require.Equal(t, "env-token", token)
+
+	_, err = chainTokenProvider{EnvTokenProvider{Name: "EMPTY_TOKEN"}, StaticTokenProvider("")}.Token()
+	require.Error(t, err)
+}
+
+func TestRunnerTokenProvider(t *testing.T) {
+	t.Run("static", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{}, WithTokenProvider(StaticTokenProvider("static-token")))
+		args, port, err := r.args(EventKindPush, "", "workflow.yml", "payload.json")
+		require.NoError(t, err)
+		defer markPortAsFree(port)
+		require.Contains(t, args, "GITHUB_TOKEN=static-token")
+		require.NotContains(t, args, "--action-offline-mode")
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		r := newTestRunner(t, &ScriptedExecutor{}, WithTokenProvider(AnonymousTokenProvider{}))
+		args, port, err := r.args(EventKindPush, "", "workflow.yml", "payload.json")
+		require.NoError(t, err)
+		defer markPortAsFree(port)
+		require.NotContains(t, args, "--secret")
+		require.Subset(t, args, []string{"--action-offline-mode", "--pull=false"})
+		require.NotContains(t, r.secretValues(), "")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := NewRunner(t, WithExecutor(&ScriptedExecutor{}), WithTokenProvider(StaticTokenProvider("")))
+		require.Error(t, err)
+	})
+}
@@ -1,11 +1,12 @@
 package act
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
 )
@@ -16,11 +17,16 @@ type EventKind string
 // EventKind enum values
 
 const (
-	EventKindPush              EventKind = "push"
-	EventKindPullRequest       EventKind = "pull_request"
-	EventKindPullRequestTarget EventKind = "pull_request_target"
-	EventKindRelease           EventKind = "release"
-	EventKindWorkflowDispatch  EventKind = "workflow_dispatch"
+	EventKindPush               EventKind = "push"
+	EventKindPullRequest        EventKind = "pull_request"
+	EventKindPullRequestTarget  EventKind = "pull_request_target"
+	EventKindPullRequestReview  EventKind = "pull_request_review"
+	EventKindMergeGroup         EventKind = "merge_group"
+	EventKindRelease            EventKind = "release"
+	EventKindSchedule           EventKind = "schedule"
+	EventKindWorkflowRun        EventKind = "workflow_run"
+	EventKindRepositoryDispatch EventKind = "repository_dispatch"
+	EventKindWorkflowDispatch   EventKind = "workflow_dispatch"
 )
 
 // Event represents the event with a name and payload to pass to act.
@@ -28,7 +34,7 @@ const (
 // event payload should follow the GitHub pull_request webhook event structure:
 // https://docs.github.com/en/webhooks/webhook-events-and-payloads#pull_request
 //
-// By default, the payload includes an additional `{ "act": true }` key-value pair in the payload,
+// The payload always includes an additional `{ "act": true }` key-value pair when passed to act,
 // which makes it possible to detect when the workflow is running under act in the workflow itself:
 //
 // ```yaml
@@ -45,14 +51,23 @@ type Event struct {
 	// The default (empty) will use `nektos/act`.
 	Actor string
 
-	// Payload is the event payload data (JSON serializable).
+	// Payload is the event payload data (JSON serializable, as a JSON object).
+	// The builders return typed payloads (e.g.: *PushPayload or *PullRequestPayload) for the most
+	// common events, and a map[string]any for the others.
 	// See the GitHub "webhooks and events payload" documentation
 	// for the schema of different event payloads:
 	// https://docs.github.com/en/webhooks/webhook-events-and-payloads
-	Payload map[string]any
+	Payload any
+}
+
+// PayloadJSON returns the JSON payload of the event, as passed to act,
+// including the additional "act": true key-value pair.
+func (e Event) PayloadJSON() ([]byte, error) {
+	return marshalPayload(e.Payload)
 }
 
 // EventOption is a function that configures an Event.
+// The options that only apply to some events (e.g.: WithForkPR) are ignored for the other events.
 type EventOption func(e *Event)
 
 // WithEventActor sets the actor of the Event, in order to impersonate
@@ -64,40 +79,119 @@ func WithEventActor(actor string) EventOption {
 }
 
 // WithForkPR marks a pull request event as coming from a fork.
-// The original event must be created with NewPullRequestEventPayload.
+// The original event must be created with NewPullRequestEventPayload or NewPullRequestReviewEventPayload.
 // This sets the pull_request.head.repo.full_name to a different value than
 // the repository.full_name, which makes the workflow detect it as a fork PR.
 // The fork repository name defaults to "fork-user/plugin-ci-workflows" but can be
 // customized by providing a forkRepo parameter.
 func WithForkPR(forkRepo ...string) EventOption {
 	return func(e *Event) {
-		if e.Kind != EventKindPullRequest {
+		pr := payloadPullRequest(e.Payload)
+		if pr == nil {
 			return // Only applies to pull request events
 		}
 		forkRepoName := "fork-user/plugin-ci-workflows"
 		if len(forkRepo) > 0 && forkRepo[0] != "" {
 			forkRepoName = forkRepo[0]
 		}
-		pr := e.Payload["pull_request"].(map[string]any)
-		head := pr["head"].(map[string]any)
-		repo := head["repo"].(map[string]any)
-		repo["full_name"] = forkRepoName
+		owner, name, _ := strings.Cut(forkRepoName, "/")
+		repo := &pr.Head.Repo
+		repo.ID = 2
+		repo.FullName = forkRepoName
+		repo.Name = name
+		repo.Owner = User{Login: owner, ID: 3, Type: "User"}
+		repo.HTMLURL = "https://github.com/" + forkRepoName
+		repo.Fork = true
+		pr.Head.User = repo.Owner
+		pr.Head.Label = owner + ":" + pr.Head.Ref
+	}
+}
+
+// WithPRLabels adds the given labels to the pull request of a pull request event.
+func WithPRLabels(labels ...string) EventOption {
+	return func(e *Event) {
+		pr := payloadPullRequest(e.Payload)
+		if pr == nil {
+			return
+		}
+		for _, label := range labels {
+			pr.Labels = append(pr.Labels, Label{ID: int64(len(pr.Labels) + 1), Name: label, Color: "ededed"})
+		}
+	}
+}
+
+// WithDraftPR marks the pull request of a pull request event as a draft.
+func WithDraftPR() EventOption {
+	return func(e *Event) {
+		if pr := payloadPullRequest(e.Payload); pr != nil {
+			pr.Draft = true
+		}
+	}
+}
+
+// WithMergedPR marks the pull request of a pull request event as merged.
+// For pull_request events, it also sets the action to "closed", which is how GitHub reports merges.
+func WithMergedPR() EventOption {
+	return func(e *Event) {
+		pr := payloadPullRequest(e.Payload)
+		if pr == nil {
+			return
+		}
+		mergedAt := fixedTimestamp
+		pr.State = "closed"
+		pr.Merged = true
+		pr.MergedAt = &mergedAt
+		if p, ok := e.Payload.(*PullRequestPayload); ok {
+			p.Action = "closed"
+		}
+	}
+}
+
+// WithHeadSHA sets the SHA of the head commit of a push or pull request event.
+// For push events, it sets "after" and "head_commit", which act uses as github.sha.
+// For pull request events, it sets pull_request.head.sha.
+// By default, the events don't have a SHA, so github.sha is the HEAD of the local repository.
+func WithHeadSHA(sha string) EventOption {
+	return func(e *Event) {
+		if pr := payloadPullRequest(e.Payload); pr != nil {
+			pr.Head.SHA = sha
+			return
+		}
+		p, ok := e.Payload.(*PushPayload)
+		if !ok {
+			return
+		}
+		p.After = sha
+		if p.Before == "" {
+			p.Before = strings.Repeat("0", len(sha))
+		}
+		p.HeadCommit = &Commit{
+			ID:        sha,
+			TreeID:    sha,
+			Message:   "Test commit",
+			Timestamp: fixedTimestamp,
+			Author:    p.Pusher,
+			Committer: p.Pusher,
+		}
+		p.Commits = []Commit{*p.HeadCommit}
 	}
 }
 
-// NewEventPayload creates a new EventPayload with the given data.
-// It always includes an "act": true key-value pair.
+// NewEventPayload creates a new EventPayload with the given (untyped) data.
+// The typed builders (e.g.: NewPushEventPayload) should be preferred when available.
 func NewEventPayload(kind EventKind, data map[string]any, opts ...EventOption) Event {
 	if data == nil {
 		data = map[string]any{}
 	}
+	return newEvent(kind, data, opts...)
+}
+
+// newEvent creates a new Event with the given payload and applies the options.
+func newEvent(kind EventKind, payload any, opts ...EventOption) Event {
 	e := Event{
 		Kind:    kind,
-		Payload: data,
+		Payload: payload,
 	}
-	// Default data that should always be present in the payload
-	e.Payload["act"] = true
-	// Apply options
 	for _, opt := range opts {
 		opt(&e)
 	}
@@ -105,44 +199,194 @@ func NewEventPayload(kind EventKind, data map[string]any, opts ...EventOption) E
 }
 
 // NewPushEventPayload creates a new EventPayload for a push event on the given branch.
+// To set the SHA of the pushed commit, use the WithHeadSHA option.
 func NewPushEventPayload(branch string, opts ...EventOption) Event {
-	return NewEventPayload(EventKindPush, map[string]any{
-		"ref": "refs/heads/" + branch,
-	}, opts...)
+	return newEvent(EventKindPush, newPushPayload("refs/heads/"+branch), opts...)
+}
+
+// NewTagPushEventPayload creates a new EventPayload for a push event of the given tag (e.g.: "v1.2.3").
+func NewTagPushEventPayload(tag string, opts ...EventOption) Event {
+	payload := newPushPayload("refs/tags/" + tag)
+	baseRef := "refs/heads/main"
+	payload.BaseRef = &baseRef
+	payload.Created = true
+	return newEvent(EventKindPush, payload, opts...)
+}
+
+// newPushPayload returns the payload of a push event for the given ref.
+func newPushPayload(ref string) *PushPayload {
+	return &PushPayload{
+		Ref:        ref,
+		Commits:    []Commit{},
+		Repository: repository(),
+		Pusher:     CommitAuthor{Name: "nektos/act", Email: "act@example.com"},
+		Sender:     sender(),
+	}
+}
+
+// repositoryFullName is the full name of the repository used in the event payloads.
+const repositoryFullName = "grafana/plugin-ci-workflows"
+
+// repositoryPayload returns the "repository" object of the untyped event payloads.
+func repositoryPayload() map[string]any {
+	return map[string]any{
+		"full_name":      repositoryFullName,
+		"name":           "plugin-ci-workflows",
+		"default_branch": "main",
+		"owner": map[string]any{
+			"login": "grafana",
+		},
+	}
 }
 
 // NewPullRequestEventPayload creates a new EventPayload for a pull request event
 // from a branch with the given name.
 // By default, it creates a non-fork PR (head repo same as base repo).
-// To create a fork PR, use the WithForkPR option.
+// To create a fork PR, use the WithForkPR option. WithPRLabels, WithDraftPR, WithMergedPR
+// and WithHeadSHA can be used to further customize the pull request.
 func NewPullRequestEventPayload(prBranch string, opts ...EventOption) Event {
-	return NewEventPayload(EventKindPullRequest, map[string]any{
-		"action": "opened",
-		"repository": map[string]any{
-			"full_name": "grafana/plugin-ci-workflows",
+	return newEvent(EventKindPullRequest, &PullRequestPayload{
+		Action:      "opened",
+		Number:      1,
+		PullRequest: pullRequest(prBranch),
+		Repository:  repository(),
+		Sender:      sender(),
+	}, opts...)
+}
+
+// NewPullRequestReviewEventPayload creates a new EventPayload for a pull_request_review event
+// (submitted review with the given state, e.g.: "approved") on a pull request from the given branch.
+// To create a review on a fork PR, use the WithForkPR option.
+func NewPullRequestReviewEventPayload(prBranch string, state string, opts ...EventOption) Event {
+	return newEvent(EventKindPullRequestReview, &PullRequestReviewPayload{
+		Action: "submitted",
+		Review: Review{
+			ID:    1,
+			State: state,
+			User:  User{Login: "reviewer", ID: 4, Type: "User"},
 		},
-		"pull_request": map[string]any{
-			"head": map[string]any{
-				"ref": prBranch,
-				"repo": map[string]any{
-					"full_name": "grafana/plugin-ci-workflows",
-				},
-			},
-			"base": map[string]any{
-				"ref": "main",
+		PullRequest: pullRequest(prBranch),
+		Repository:  repository(),
+		Sender:      sender(),
+	}, opts...)
+}
+
+// NewMergeGroupEventPayload creates a new EventPayload for a merge_group event (checks requested)
+// for the merge queue of the given base branch, with the given head commit SHA.
+func NewMergeGroupEventPayload(baseBranch string, headSHA string, opts ...EventOption) Event {
+	return NewEventPayload(EventKindMergeGroup, map[string]any{
+		"action": "checks_requested",
+		"merge_group": map[string]any{
+			"head_sha": headSHA,
+			"head_ref": "refs/heads/gh-readonly-queue/" + baseBranch + "/pr-1-" + headSHA,
+			"base_ref": "refs/heads/" + baseBranch,
+			"head_commit": map[string]any{
+				"id":      headSHA,
+				"message": "Merge pull request #1",
 			},
 		},
+		"repository": repositoryPayload(),
+	}, opts...)
+}
+
+// NewReleaseEventPayload creates a new EventPayload for a release event (published) for the given tag.
+func NewReleaseEventPayload(tag string, opts ...EventOption) Event {
+	publishedAt := fixedTimestamp
+	return newEvent(EventKindRelease, &ReleasePayload{
+		Action: "published",
+		Release: Release{
+			ID:              1,
+			TagName:         tag,
+			TargetCommitish: "main",
+			Name:            tag,
+			CreatedAt:       fixedTimestamp,
+			PublishedAt:     &publishedAt,
+			HTMLURL:         "https://github.com/" + repositoryFullName + "/releases/tag/" + tag,
+			Author:          sender(),
+		},
+		Repository: repository(),
+		Sender:     sender(),
+	}, opts...)
+}
+
+// NewScheduleEventPayload creates a new EventPayload for a schedule event with the given cron expression.
+func NewScheduleEventPayload(cron string, opts ...EventOption) Event {
+	return NewEventPayload(EventKindSchedule, map[string]any{
+		"schedule":   cron,
+		"repository": repositoryPayload(),
+	}, opts...)
+}
+
+// NewWorkflowRunEventPayload creates a new EventPayload for a workflow_run event (completed)
+// of the workflow with the given name, with the given conclusion (e.g.: "success").
+func NewWorkflowRunEventPayload(workflowName string, conclusion string, opts ...EventOption) Event {
+	return NewEventPayload(EventKindWorkflowRun, map[string]any{
+		"action": "completed",
+		"workflow_run": map[string]any{
+			"id":          1,
+			"name":        workflowName,
+			"event":       "push",
+			"status":      "completed",
+			"conclusion":  conclusion,
+			"head_branch": "main",
+		},
+		"workflow": map[string]any{
+			"name": workflowName,
+		},
+		"repository": repositoryPayload(),
+	}, opts...)
+}
+
+// NewRepositoryDispatchEventPayload creates a new EventPayload for a repository_dispatch event
+// with the given event type and client payload.
+func NewRepositoryDispatchEventPayload(eventType string, clientPayload map[string]any, opts ...EventOption) Event {
+	return NewEventPayload(EventKindRepositoryDispatch, map[string]any{
+		"action":         eventType,
+		"branch":         "main",
+		"client_payload": clientPayload,
+		"repository":     repositoryPayload(),
 	}, opts...)
 }
 
 // NewWorkflowDispatchEventPayload creates a new EventPayload for a workflow_dispatch event
 // with the given inputs. This can be used to test workflows that are manually triggered.
+// The ref is not set, so act uses the ref of the local repository.
+// The dispatched workflow is the workflow file run by the Runner, unless set with WithDispatchedWorkflow.
 func NewWorkflowDispatchEventPayload(inputs map[string]any, opts ...EventOption) Event {
-	return NewEventPayload(EventKindWorkflowDispatch, map[string]any{
-		"inputs": inputs,
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+	return newEvent(EventKindWorkflowDispatch, &WorkflowDispatchPayload{
+		Inputs:     inputs,
+		Repository: repository(),
+		Sender:     sender(),
 	}, opts...)
 }
 
+// WithDispatchedWorkflow sets the path of the dispatched workflow of a workflow_dispatch event
+// (e.g.: ".github/workflows/cd.yml").
+func WithDispatchedWorkflow(path string) EventOption {
+	return func(e *Event) {
+		if p, ok := e.Payload.(*WorkflowDispatchPayload); ok {
+			p.Workflow = path
+		}
+	}
+}
+
+// withDefaultDispatchedWorkflow returns the given event with the dispatched workflow of a workflow_dispatch event
+// set to the given workflow file, unless it was set with WithDispatchedWorkflow.
+// The payload is copied, so the event can be used again to run other workflows.
+func withDefaultDispatchedWorkflow(event Event, workflowFile string) Event {
+	p, ok := event.Payload.(*WorkflowDispatchPayload)
+	if !ok || p.Workflow != "" {
+		return event
+	}
+	payload := *p
+	payload.Workflow = filepath.ToSlash(workflowFile)
+	event.Payload = &payload
+	return event
+}
+
 // CreateTempEventFile creates a temporary file in a temporary folder
 // containing the payload from the given event in JSON format.
 // The function returns the path to the created file.
@@ -157,8 +401,12 @@ func CreateTempEventFile(event Event) (filename string, err error) {
 			err = closeErr
 		}
 	}()
-	if err := json.NewEncoder(f).Encode(event.Payload); err != nil {
-		return "", fmt.Errorf("encode event to temp file: %w", err)
+	content, err := event.PayloadJSON()
+	if err != nil {
+		return "", fmt.Errorf("encode event: %w", err)
+	}
+	if _, err := f.Write(content); err != nil {
+		return "", fmt.Errorf("write temp event file: %w", err)
 	}
 	return f.Name(), nil
 }
@@ -185,6 +433,20 @@ func CreateTempWorkflowFile(workflow workflow.Workflow) (string, error) {
 	return fn, nil
 }
 
+// RemoveTempWorkflowFiles removes the temporary workflow files created by CreateTempWorkflowFile
+// for the given workflow, including the ones of its child workflows (recursively).
+// Files that don't exist are ignored.
+func RemoveTempWorkflowFiles(workflow workflow.Workflow) error {
+	err := os.Remove(filepath.Join(".github", "workflows", workflow.FileName()))
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	for _, child := range workflow.Children() {
+		err = errors.Join(err, RemoveTempWorkflowFiles(child))
+	}
+	return err
+}
+
 // CleanupTempWorkflowFiles removes all temporary workflow files created for act tests
 // that were created inside .github/workflows by CreateTempWorkflowFile.
 func CleanupTempWorkflowFiles() error {
@@ -0,0 +1,213 @@
+package act
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/require"
+)
+
+// eventPayloadFields returns the JSON payload of the given event, as passed to act, as a map.
+func eventPayloadFields(t *testing.T, e Event) map[string]any {
+	t.Helper()
+	content, err := e.PayloadJSON()
+	require.NoError(t, err)
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(content, &fields))
+	return fields
+}
+
+func TestEventPayloads(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		event     Event
+		expKind   EventKind
+		expFields map[string]any
+	}{
+		{
+			name:      "tag push",
+			event:     NewTagPushEventPayload("v1.2.3"),
+			expKind:   EventKindPush,
+			expFields: map[string]any{"ref": "refs/tags/v1.2.3", "created": true},
+		},
+		{
+			name:      "release published",
+			event:     NewReleaseEventPayload("v1.2.3"),
+			expKind:   EventKindRelease,
+			expFields: map[string]any{"action": "published"},
+		},
+		{
+			name:      "schedule",
+			event:     NewScheduleEventPayload("0 0 * * *"),
+			expKind:   EventKindSchedule,
+			expFields: map[string]any{"schedule": "0 0 * * *"},
+		},
+		{
+			name:      "workflow run",
+			event:     NewWorkflowRunEventPayload("CI", "success"),
+			expKind:   EventKindWorkflowRun,
+			expFields: map[string]any{"action": "completed"},
+		},
+		{
+			name:      "repository dispatch",
+			event:     NewRepositoryDispatchEventPayload("deploy", map[string]any{"env": "dev"}),
+			expKind:   EventKindRepositoryDispatch,
+			expFields: map[string]any{"action": "deploy", "client_payload": map[string]any{"env": "dev"}},
+		},
+		{
+			name:      "merge group",
+			event:     NewMergeGroupEventPayload("main", "abc123"),
+			expKind:   EventKindMergeGroup,
+			expFields: map[string]any{"action": "checks_requested"},
+		},
+		{
+			name:      "pull request review",
+			event:     NewPullRequestReviewEventPayload("feature", "approved"),
+			expKind:   EventKindPullRequestReview,
+			expFields: map[string]any{"action": "submitted"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expKind, tc.event.Kind)
+			fields := eventPayloadFields(t, tc.event)
+			require.Equal(t, true, fields["act"])
+			for k, v := range tc.expFields {
+				require.Equal(t, v, fields[k], "wrong value for %q", k)
+			}
+			require.Equal(t, repositoryFullName, fields["repository"].(map[string]any)["full_name"])
+		})
+	}
+
+	t.Run("nested fields", func(t *testing.T) {
+		release := NewReleaseEventPayload("v1.2.3").Payload.(*ReleasePayload).Release
+		require.Equal(t, "v1.2.3", release.TagName)
+		mergeGroup := NewMergeGroupEventPayload("main", "abc123").Payload.(map[string]any)["merge_group"].(map[string]any)
+		require.Equal(t, "refs/heads/main", mergeGroup["base_ref"])
+		require.Equal(t, "abc123", mergeGroup["head_sha"])
+		review := NewPullRequestReviewEventPayload("feature", "approved", WithForkPR()).Payload.(*PullRequestReviewPayload)
+		require.Equal(t, "approved", review.Review.State)
+		require.Equal(t, "feature", review.PullRequest.Head.Ref)
+		require.Equal(t, "fork-user/plugin-ci-workflows", review.PullRequest.Head.Repo.FullName)
+	})
+
+	t.Run("untyped payload", func(t *testing.T) {
+		data := map[string]any{"action": "opened"}
+		e := NewEventPayload(EventKindPullRequestTarget, data, WithForkPR(), WithPRLabels("bug"), WithDraftPR(), WithMergedPR(), WithHeadSHA("abc123"))
+		require.Equal(t, map[string]any{"action": "opened"}, e.Payload, "pull request options should not apply to untyped payloads")
+		require.Equal(t, map[string]any{"action": "opened", "act": true}, eventPayloadFields(t, e))
+	})
+}
+
+func TestEventOptions(t *testing.T) {
+	t.Run("pull request", func(t *testing.T) {
+		e := NewPullRequestEventPayload(
+			"feature",
+			WithHeadSHA("abc123"),
+			WithPRLabels("bug", "dependencies"),
+			WithForkPR("someone/fork"),
+			WithDraftPR(),
+			WithMergedPR(),
+		)
+		p := e.Payload.(*PullRequestPayload)
+		require.Equal(t, "closed", p.Action)
+		pr := p.PullRequest
+		require.Equal(t, "closed", pr.State)
+		require.True(t, pr.Merged)
+		require.NotNil(t, pr.MergedAt)
+		require.True(t, pr.Draft)
+		require.Equal(t, []string{"bug", "dependencies"}, []string{pr.Labels[0].Name, pr.Labels[1].Name})
+		require.Equal(t, "abc123", pr.Head.SHA)
+		require.Equal(t, "someone/fork", pr.Head.Repo.FullName)
+		require.Equal(t, "someone:feature", pr.Head.Label)
+		require.True(t, pr.Head.Repo.Fork)
+		require.Equal(t, repositoryFullName, pr.Base.Repo.FullName, "base repo should not change")
+		require.Equal(t, repositoryFullName, p.Repository.FullName, "repository should not change")
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		fields := eventPayloadFields(t, NewPullRequestEventPayload("feature"))
+		head := fields["pull_request"].(map[string]any)["head"].(map[string]any)
+		require.NotContains(t, head, "sha", "head sha should not be set by default, so github.sha is used")
+		require.Equal(t, repositoryFullName, head["repo"].(map[string]any)["full_name"])
+
+		fields = eventPayloadFields(t, NewPushEventPayload("main"))
+		require.NotContains(t, fields, "after", "after should not be set by default, so github.sha is used")
+		require.Nil(t, fields["head_commit"])
+	})
+
+	t.Run("push", func(t *testing.T) {
+		p := NewPushEventPayload("main", WithHeadSHA("abc123"), WithForkPR(), WithDraftPR()).Payload.(*PushPayload)
+		require.Equal(t, "abc123", p.After)
+		require.Equal(t, "000000", p.Before)
+		require.Equal(t, "abc123", p.HeadCommit.ID)
+		require.Len(t, p.Commits, 1)
+	})
+	t.Run("workflow dispatch", func(t *testing.T) {
+		// The Runner sets the dispatched workflow to the workflow file it runs, without changing the event
+		e := NewWorkflowDispatchEventPayload(nil)
+		p := withDefaultDispatchedWorkflow(e, filepath.Join(".github", "workflows", "test.yml")).Payload.(*WorkflowDispatchPayload)
+		require.Equal(t, ".github/workflows/test.yml", p.Workflow)
+		require.Empty(t, e.Payload.(*WorkflowDispatchPayload).Workflow)
+
+		e = NewWorkflowDispatchEventPayload(nil, WithDispatchedWorkflow(".github/workflows/cd.yml"))
+		p = withDefaultDispatchedWorkflow(e, filepath.Join(".github", "workflows", "test.yml")).Payload.(*WorkflowDispatchPayload)
+		require.Equal(t, ".github/workflows/cd.yml", p.Workflow)
+	})
+}
+
+// The schemas describe the output of the builders, not the payloads sent by GitHub. See testdata/payloads/README.md.
+func TestEventPayloadShapes(t *testing.T) {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	for _, tc := range []struct {
+		name   string
+		schema string
+		event  Event
+	}{
+		{name: "push", schema: "push", event: NewPushEventPayload("main")},
+		{name: "push with head sha", schema: "push", event: NewPushEventPayload("main", WithHeadSHA("0123456789abcdef0123456789abcdef01234567"))},
+		{name: "tag push", schema: "push", event: NewTagPushEventPayload("v1.2.3")},
+		{name: "pull request", schema: "pull_request", event: NewPullRequestEventPayload("feature")},
+		{
+			name:   "pull request with options",
+			schema: "pull_request",
+			event: NewPullRequestEventPayload(
+				"feature",
+				WithForkPR(),
+				WithPRLabels("bug"),
+				WithDraftPR(),
+				WithHeadSHA("0123456789abcdef0123456789abcdef01234567"),
+			),
+		},
+		{name: "merged pull request", schema: "pull_request", event: NewPullRequestEventPayload("feature", WithMergedPR())},
+		{name: "release", schema: "release", event: NewReleaseEventPayload("v1.2.3")},
+		{name: "workflow dispatch", schema: "workflow_dispatch", event: NewWorkflowDispatchEventPayload(map[string]any{"branch": "main"})},
+		{name: "workflow dispatch without inputs", schema: "workflow_dispatch", event: NewWorkflowDispatchEventPayload(nil)},
+		{name: "workflow dispatch with workflow", schema: "workflow_dispatch", event: NewWorkflowDispatchEventPayload(nil, WithDispatchedWorkflow(".github/workflows/cd.yml"))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := compiler.Compile(filepath.Join("testdata", "payloads", tc.schema+".schema.json"))
+			require.NoError(t, err)
+			content, err := tc.event.PayloadJSON()
+			require.NoError(t, err)
+			payload, err := jsonschema.UnmarshalJSON(bytes.NewReader(content))
+			require.NoError(t, err)
+			require.NoError(t, schema.Validate(payload))
+		})
+	}
+
+	t.Run("invalid payload", func(t *testing.T) {
+		schema, err := compiler.Compile(filepath.Join("testdata", "payloads", "pull_request.schema.json"))
+		require.NoError(t, err)
+		e := NewPullRequestEventPayload("feature")
+		e.Payload.(*PullRequestPayload).PullRequest.Labels = append(e.Payload.(*PullRequestPayload).PullRequest.Labels, Label{Name: "bug", Color: "not a color"})
+		content, err := e.PayloadJSON()
+		require.NoError(t, err)
+		payload, err := jsonschema.UnmarshalJSON(bytes.NewReader(content))
+		require.NoError(t, err)
+		require.Error(t, schema.Validate(payload))
+	})
+}
@@ -0,0 +1,286 @@
+// Package golden provides assertions comparing afero filesystems (like act.ArtifactFolder.Fs and act.GCS.Fs)
+// against golden trees stored in testdata.
+//
+// A golden tree is a text file listing the paths of the files in the filesystem, one per line, sorted.
+// Optionally, each path is followed by the SHA-256 of the content of the file.
+// Run the tests with the -update flag to regenerate the golden trees.
+// The flag is only defined in the test binaries of the packages importing golden,
+// so go test ./... -update fails: run it on those packages only, e.g. go test . -run TestPackage -update.
+package golden
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// update is the flag that makes the assertions regenerate the golden trees, instead of comparing against them.
+var update = flag.Bool("update", false, "update the golden trees in testdata")
+
+// treeHeader is the comment at the top of the golden tree files.
+const treeHeader = "# Golden tree. Regenerate with: go test <package> -run <test> -update"
+
+// Placeholder replaces the parts of the paths (and hashed contents) matching Regex with {{Name}},
+// so the golden trees don't depend on values that change between runs, like versions and commit SHAs.
+type Placeholder struct {
+	// Name is the name of the placeholder, written as {{Name}} in the golden trees.
+	Name string
+
+	// Regex matches the values to replace.
+	Regex *regexp.Regexp
+}
+
+var (
+	// VersionPlaceholder replaces semantic versions (e.g.: 1.2.3 or 1.2.3-rc.1) with {{VERSION}}.
+	VersionPlaceholder = Placeholder{Name: "VERSION", Regex: regexp.MustCompile(`\b\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?\b`)}
+
+	// SHAPlaceholder replaces full git commit SHAs, and other hex SHA-1 checksums, with {{SHA}}.
+	SHAPlaceholder = Placeholder{Name: "SHA", Regex: regexp.MustCompile(`\b[0-9a-f]{40}\b`)}
+
+	// MD5Placeholder replaces hex MD5 checksums with {{MD5}}.
+	MD5Placeholder = Placeholder{Name: "MD5", Regex: regexp.MustCompile(`\b[0-9a-f]{32}\b`)}
+)
+
+// Option configures a tree assertion.
+type Option func(o *options)
+
+// options are the options of a tree assertion.
+type options struct {
+	ignore       []*regexp.Regexp
+	hash         []*regexp.Regexp
+	placeholders []Placeholder
+}
+
+// WithIgnore ignores the files matching any of the given globs.
+// Globs are matched against slash-separated paths relative to the root of the filesystem.
+// "*" matches any sequence of characters except "/", "**" matches any sequence of characters.
+func WithIgnore(globs ...string) Option {
+	return func(o *options) {
+		for _, g := range globs {
+			o.ignore = append(o.ignore, globRegex(g))
+		}
+	}
+}
+
+// WithContentHash includes the SHA-256 of the content of the files matching any of the given globs
+// (all the files if none are given) in the golden tree.
+// Placeholders are replaced in the content before hashing.
+func WithContentHash(globs ...string) Option {
+	return func(o *options) {
+		if len(globs) == 0 {
+			globs = []string{"**"}
+		}
+		for _, g := range globs {
+			o.hash = append(o.hash, globRegex(g))
+		}
+	}
+}
+
+// WithPlaceholders replaces the values matching the given placeholders, in order.
+func WithPlaceholders(placeholders ...Placeholder) Option {
+	return func(o *options) {
+		o.placeholders = append(o.placeholders, placeholders...)
+	}
+}
+
+// globRegex returns the regular expression matching the given glob.
+func globRegex(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// matchesAny returns true if the given path matches any of the given regular expressions.
+func matchesAny(path string, regexes []*regexp.Regexp) bool {
+	return slices.ContainsFunc(regexes, func(r *regexp.Regexp) bool {
+		return r.MatchString(path)
+	})
+}
+
+// replacePlaceholders replaces the values matching the placeholders in the given content.
+func (o *options) replacePlaceholders(content []byte) []byte {
+	for _, p := range o.placeholders {
+		content = p.Regex.ReplaceAll(content, []byte("{{"+p.Name+"}}"))
+	}
+	return content
+}
+
+// Tree returns the entries of the golden tree for the given filesystem, sorted:
+// the path of each file, followed by the hash of its content when requested.
+func Tree(fs afero.Fs, opts ...Option) ([]string, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var entries []string
+	err := afero.Walk(fs, "/", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath := strings.TrimPrefix(filepath.ToSlash(path), "/")
+		if matchesAny(relPath, o.ignore) {
+			return nil
+		}
+		entry := string(o.replacePlaceholders([]byte(relPath)))
+		if matchesAny(relPath, o.hash) {
+			content, err := afero.ReadFile(fs, path)
+			if err != nil {
+				return fmt.Errorf("read %q: %w", path, err)
+			}
+			h := sha256.Sum256(o.replacePlaceholders(content))
+			entry += " sha256:" + hex.EncodeToString(h[:])
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk tree: %w", err)
+	}
+	slices.Sort(entries)
+	return entries, nil
+}
+
+// AssertTree asserts that the given filesystem matches the golden tree in the given file.
+// On mismatch, the test fails with a diff of the trees.
+// With the -update flag, the golden tree is (re)generated instead.
+func AssertTree(t testing.TB, fs afero.Fs, goldenPath string, opts ...Option) {
+	t.Helper()
+	assertTree(t, fs, goldenPath, *update, opts...)
+}
+
+// assertTree is AssertTree, with the value of the -update flag passed in as update.
+func assertTree(t testing.TB, fs afero.Fs, goldenPath string, update bool, opts ...Option) {
+	t.Helper()
+	actual, err := Tree(fs, opts...)
+	if err != nil {
+		t.Fatalf("golden tree %q: %v", goldenPath, err)
+	}
+	if update {
+		if err := writeTree(goldenPath, actual); err != nil {
+			t.Fatalf("update golden tree: %v", err)
+		}
+		t.Logf("updated golden tree %q", goldenPath)
+		return
+	}
+	expected, err := readTree(goldenPath)
+	if err != nil {
+		t.Fatalf("read golden tree (run with -update to create it): %v", err)
+	}
+	if diff := DiffTrees(expected, actual); diff != "" {
+		t.Errorf("tree doesn't match golden tree %q (run with -update to regenerate it):\n%s", goldenPath, diff)
+	}
+}
+
+// readTree reads the entries of the golden tree in the given file, skipping comments and empty lines.
+func readTree(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	slices.Sort(entries)
+	return entries, nil
+}
+
+// writeTree writes the given entries to the golden tree file, creating its directory if needed.
+func writeTree(path string, entries []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create golden tree directory: %w", err)
+	}
+	content := treeHeader + "\n" + strings.Join(entries, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write golden tree: %w", err)
+	}
+	return nil
+}
+
+// DiffTrees returns a diff of the given (sorted) golden tree entries, or an empty string if they match.
+// Missing entries are prefixed with "-", unexpected ones with "+" and the ones whose content hash
+// changed with "~". Matching entries are listed without prefix, for context.
+func DiffTrees(expected, actual []string) string {
+	split := func(entries []string) map[string]string {
+		m := make(map[string]string, len(entries))
+		for _, e := range entries {
+			path, hash, _ := strings.Cut(e, " ")
+			m[path] = hash
+		}
+		return m
+	}
+	expHashes, actHashes := split(expected), split(actual)
+	paths := map[string]struct{}{}
+	for p := range expHashes {
+		paths[p] = struct{}{}
+	}
+	for p := range actHashes {
+		paths[p] = struct{}{}
+	}
+
+	var sb strings.Builder
+	changed := false
+	for _, p := range slices.Sorted(maps.Keys(paths)) {
+		expHash, inExp := expHashes[p]
+		actHash, inAct := actHashes[p]
+		switch {
+		case !inAct:
+			fmt.Fprintf(&sb, "- %s\n", p)
+			changed = true
+		case !inExp:
+			fmt.Fprintf(&sb, "+ %s\n", p)
+			changed = true
+		case expHash != actHash:
+			fmt.Fprintf(&sb, "~ %s (%s -> %s)\n", p, hashOrNone(expHash), hashOrNone(actHash))
+			changed = true
+		default:
+			fmt.Fprintf(&sb, "  %s\n", p)
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return sb.String()
+}
+
+// hashOrNone returns the given hash, or "no hash" if empty.
+func hashOrNone(hash string) string {
+	if hash == "" {
+		return "no hash"
+	}
+	return hash
+}
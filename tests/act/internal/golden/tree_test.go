@@ -0,0 +1,93 @@
+package golden
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestFs returns a filesystem similar to the mocked GCS after an upload.
+func newTestFs(t *testing.T) afero.Fs {
+	fs := afero.NewMemMapFs()
+	for fn, content := range map[string]string{
+		"artifacts/plugin/1.2.3/main/0123456789abcdef0123456789abcdef01234567/plugin-1.2.3.zip": "zip",
+		"artifacts/plugin/1.2.3/main/latest/plugin-1.2.3.zip":                                   "zip",
+		"artifacts/plugin/1.2.3/main/latest/plugin.json":                                        `{"version": "1.2.3"}`,
+		"artifacts/plugin/1.2.3/main/latest/plugin-1.2.3.zip.md5":                               "0123456789abcdef0123456789abcdef",
+		"artifacts/plugin/1.2.3/main/latest/plugin-1.2.3.zip.sha1":                              "0123456789abcdef0123456789abcdef01234567",
+		"artifacts/.DS_Store": "junk",
+	} {
+		require.NoError(t, afero.WriteFile(fs, "/"+fn, []byte(content), 0o644))
+	}
+	return fs
+}
+
+func TestTree(t *testing.T) {
+	entries, err := Tree(
+		newTestFs(t),
+		WithIgnore("**/.DS_Store"),
+		WithPlaceholders(VersionPlaceholder, MD5Placeholder, SHAPlaceholder),
+		WithContentHash("**/*.json", "**/*.md5", "**/*.sha1"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"artifacts/plugin/{{VERSION}}/main/latest/plugin-{{VERSION}}.zip",
+		// The checksums in the content are replaced before hashing
+		"artifacts/plugin/{{VERSION}}/main/latest/plugin-{{VERSION}}.zip.md5 sha256:2fd58b290526a7e094f247b1bb9e822534403fd3e7ee509f652e1608cd34b285",
+		"artifacts/plugin/{{VERSION}}/main/latest/plugin-{{VERSION}}.zip.sha1 sha256:a45bfe38ad92d4b05af9fbd4008390011525aefedef63556d7f6221ccb778280",
+		// The version in the content is replaced before hashing
+		"artifacts/plugin/{{VERSION}}/main/latest/plugin.json sha256:949e7f5bbd2de9997deb4f85469d6efc7bc2714fb44ea0a5cc48353e248ca7aa",
+		"artifacts/plugin/{{VERSION}}/main/{{SHA}}/plugin-{{VERSION}}.zip",
+	}, entries)
+}
+
+func TestAssertTree(t *testing.T) {
+	AssertTree(
+		t,
+		newTestFs(t),
+		filepath.Join("testdata", "gcs.tree"),
+		WithIgnore("**/.DS_Store"),
+		WithPlaceholders(VersionPlaceholder, SHAPlaceholder),
+	)
+
+	t.Run("update", func(t *testing.T) {
+		goldenPath := filepath.Join(t.TempDir(), "new", "gcs.tree")
+		assertTree(t, newTestFs(t), goldenPath, true)
+		entries, err := readTree(goldenPath)
+		require.NoError(t, err)
+		require.Len(t, entries, 6)
+	})
+}
+
+func TestDiffTrees(t *testing.T) {
+	require.Empty(t, DiffTrees([]string{"a", "b sha256:1"}, []string{"a", "b sha256:1"}))
+	require.Equal(
+		t,
+		"  a\n- b\n+ c\n~ d (sha256:1 -> sha256:2)\n~ e (no hash -> sha256:3)\n",
+		DiffTrees(
+			[]string{"a", "b", "d sha256:1", "e"},
+			[]string{"a", "c", "d sha256:2", "e sha256:3"},
+		),
+	)
+}
+
+func TestGlobRegex(t *testing.T) {
+	for _, tc := range []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{glob: "*.zip", path: "a.zip", matches: true},
+		{glob: "*.zip", path: "dir/a.zip", matches: false},
+		{glob: "**/*.zip", path: "a.zip", matches: true},
+		{glob: "**/*.zip", path: "dir/sub/a.zip", matches: true},
+		{glob: "dir/**", path: "dir/sub/a.zip", matches: true},
+		{glob: "dir/?.zip", path: "dir/a.zip", matches: true},
+		{glob: "dir/?.zip", path: "dir/ab.zip", matches: false},
+		{glob: "a.zip", path: "aXzip", matches: false},
+	} {
+		require.Equal(t, tc.matches, globRegex(tc.glob).MatchString(tc.path), "glob %q, path %q", tc.glob, tc.path)
+	}
+}
@@ -286,6 +286,7 @@ type VaultSecrets struct {
 //
 // The mocked step mimics the behavior of the original step, but instead of fetching secrets from Vault,
 // it outputs the provided secrets as a JSON object (step output named "secrets").
+// Like the original step, it masks the secret values via the add-mask workflow command.
 //
 // If a secret is not found in the provided VaultSecrets:
 //   - If DefaultValue is nil, an error is returned.
@@ -339,6 +340,8 @@ func MockVaultSecretsStep(originalStep Step, secrets VaultSecrets) (Step, error)
 		return Step{}, fmt.Errorf("marshal vault secrets to json: %w", err)
 	}
 	var stepCommands Commands
+	// Mask the secret values, like the real action does
+	stepCommands = append(stepCommands, `jq -r '.[]' <<< "${SECRETS_JSON}" | while IFS= read -r value; do echo "::add-mask::${value}"; done`)
 	stepCommands = append(stepCommands, `echo "secrets=${SECRETS_JSON}" >> "$GITHUB_OUTPUT"`)
 	step := Step{
 		Env:   map[string]string{"SECRETS_JSON": string(secretsJSON)},
@@ -375,6 +378,7 @@ func MockArgoWorkflowStep(originalStep Step, mockServerURL string) (Step, error)
 // If those conditions are not met, an error is returned.
 //
 // The mocked step outputs the `token` output expected by subsequent steps.
+// Like the original step, it masks the token via the add-mask workflow command.
 func MockGitHubAppTokenStep(originalStep Step, token string) (Step, error) {
 	if !strings.HasPrefix(originalStep.Uses, GitHubAppTokenAction) && !strings.HasPrefix(originalStep.Uses, GitHubAppTokenLegacyAction) {
 		return Step{}, fmt.Errorf("cannot mock github app token for a step that uses %q action, must be %q or %q", originalStep.Uses, GitHubAppTokenAction, GitHubAppTokenLegacyAction)
@@ -382,6 +386,8 @@ func MockGitHubAppTokenStep(originalStep Step, token string) (Step, error) {
 	return Step{
 		Run: Commands{
 			`echo "Mocking GitHub app token step"`,
+			// Mask the token, like the real action does
+			`echo "::add-mask::${MOCK_TOKEN}"`,
 			`echo "token=${MOCK_TOKEN}" >> "$GITHUB_OUTPUT"`,
 		}.String(),
 		Shell: "bash",
@@ -335,3 +335,18 @@ func TestMockArgoWorkflowStep(t *testing.T) {
 
 	require.Equal(t, mockServerURL, mockedStep.Env["HTTPSPY_MOCK_SERVER_URL"])
 }
+
+func TestMockGitHubAppTokenStep(t *testing.T) {
+	step := Step{
+		Name: "Generate GitHub token",
+		Uses: GitHubAppTokenAction + "@main",
+	}
+	mockedStep, err := MockGitHubAppTokenStep(step, "mock-token")
+	require.NoError(t, err)
+	require.Equal(t, "mock-token", mockedStep.Env["MOCK_TOKEN"])
+	require.Contains(t, mockedStep.Run, `echo "::add-mask::${MOCK_TOKEN}"`, "token should be masked")
+	require.Contains(t, mockedStep.Run, `echo "token=${MOCK_TOKEN}" >> "$GITHUB_OUTPUT"`)
+
+	_, err = MockGitHubAppTokenStep(Step{Uses: "actions/checkout@v4"}, "mock-token")
+	require.Error(t, err, "should not mock steps using other actions")
+}
@@ -251,17 +251,23 @@ func (s *Step) mockedName() string {
 
 // On is the YAML representation of GitHub Actions workflow triggers.
 type On struct {
-	Push              OnPush              `yaml:"push,omitempty"`
-	PullRequest       OnPullRequest       `yaml:"pull_request,omitempty"`
-	PullRequestTarget OnPullRequestTarget `yaml:"pull_request_target,omitempty"`
-	Release           OnRelease           `yaml:"release,omitempty"`
-	WorkflowCall      OnWorkflowCall      `yaml:"workflow_call,omitempty"`
-	WorkflowDispatch  OnWorkflowDispatch  `yaml:"workflow_dispatch,omitempty"`
+	Push               OnPush               `yaml:"push,omitempty"`
+	PullRequest        OnPullRequest        `yaml:"pull_request,omitempty"`
+	PullRequestTarget  OnPullRequestTarget  `yaml:"pull_request_target,omitempty"`
+	PullRequestReview  OnPullRequestReview  `yaml:"pull_request_review,omitempty"`
+	MergeGroup         OnMergeGroup         `yaml:"merge_group,omitempty"`
+	Release            OnRelease            `yaml:"release,omitempty"`
+	Schedule           []OnSchedule         `yaml:"schedule,omitempty"`
+	WorkflowRun        OnWorkflowRun        `yaml:"workflow_run,omitempty"`
+	RepositoryDispatch OnRepositoryDispatch `yaml:"repository_dispatch,omitempty"`
+	WorkflowCall       OnWorkflowCall       `yaml:"workflow_call,omitempty"`
+	WorkflowDispatch   OnWorkflowDispatch   `yaml:"workflow_dispatch,omitempty"`
 }
 
 // OnPush is the YAML representation of GitHub Actions push event trigger.
 type OnPush struct {
 	Branches []string `yaml:"branches,omitempty"`
+	Tags     []string `yaml:"tags,omitempty"`
 }
 
 // OnPullRequest is the YAML representation of GitHub Actions pull_request event trigger.
@@ -274,6 +280,33 @@ type OnPullRequestTarget struct {
 	Branches []string `yaml:"branches,omitempty"`
 }
 
+// OnPullRequestReview is the YAML representation of GitHub Actions pull_request_review event trigger.
+type OnPullRequestReview struct {
+	Types []string `yaml:"types,omitempty"`
+}
+
+// OnMergeGroup is the YAML representation of GitHub Actions merge_group event trigger.
+type OnMergeGroup struct {
+	Types []string `yaml:"types,omitempty"`
+}
+
+// OnSchedule is the YAML representation of a GitHub Actions schedule event trigger entry.
+type OnSchedule struct {
+	Cron string `yaml:"cron"`
+}
+
+// OnWorkflowRun is the YAML representation of GitHub Actions workflow_run event trigger.
+type OnWorkflowRun struct {
+	Workflows []string `yaml:"workflows,omitempty"`
+	Types     []string `yaml:"types,omitempty"`
+	Branches  []string `yaml:"branches,omitempty"`
+}
+
+// OnRepositoryDispatch is the YAML representation of GitHub Actions repository_dispatch event trigger.
+type OnRepositoryDispatch struct {
+	Types []string `yaml:"types,omitempty"`
+}
+
 // OnRelease is the YAML representation of GitHub Actions release event trigger.
 type OnRelease struct {
 	Types []string `yaml:"types,omitempty"`
@@ -0,0 +1,33 @@
+package workflow
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnTriggers(t *testing.T) {
+	wf := BaseWorkflow{
+		On: On{
+			Push:               OnPush{Tags: []string{"v*"}},
+			Release:            OnRelease{Types: []string{"published"}},
+			Schedule:           []OnSchedule{{Cron: "0 0 * * *"}},
+			WorkflowRun:        OnWorkflowRun{Workflows: []string{"CI"}, Types: []string{"completed"}},
+			RepositoryDispatch: OnRepositoryDispatch{Types: []string{"deploy"}},
+			MergeGroup:         OnMergeGroup{Types: []string{"checks_requested"}},
+			PullRequestReview:  OnPullRequestReview{Types: []string{"submitted"}},
+		},
+	}
+	content, err := wf.Marshal()
+	require.NoError(t, err)
+	var on struct {
+		On map[string]any `yaml:"on"`
+	}
+	require.NoError(t, yaml.Unmarshal(content, &on))
+	require.ElementsMatch(t, []string{
+		"push", "release", "schedule", "workflow_run", "repository_dispatch", "merge_group", "pull_request_review",
+	}, slices.Collect(maps.Keys(on.On)))
+}
@@ -43,7 +43,7 @@ func TestBackendBuildTarget(t *testing.T) {
 
 		expMsg, err := logfmt.MarshalKeyvals("msg", "custom build target invoked")
 		require.NoError(t, err)
-		require.Contains(t, r.Annotations, act.Annotation{
+		require.Contains(t, r.Annotations.WithoutLocation(), act.Annotation{
 			Level:   act.AnnotationLevelDebug,
 			Message: string(expMsg),
 		}, "custom build target should have printed its debug annotation")
@@ -419,10 +419,13 @@ func TestCD_Argo(t *testing.T) {
 			require.Equal(t, tc.expArgoInputs, argoInputs, "wrong argo inputs provided to argo workflow trigger step")
 
 			// Verify summary
-			require.Len(t, r.Summary, 1, "should have exactly one summary")
-			require.Contains(t, r.Summary[0], "A deployment to Grafana Cloud via the plugins CD Argo Workflow has successfully been triggered.")
-			require.Contains(t, r.Summary[0], "Plugin Version: `1.0.0`")
-			require.Contains(t, r.Summary[0], "Environment(s): `"+*tc.inputs.Environment+"`")
+			require.Len(t, r.StepSummaries, 1, "should have exactly one summary")
+			summary := r.StepSummaries[0]
+			require.Equal(t, []act.Heading{{Level: 1, Text: "🐙 Grafana Cloud deployment via Argo Workflows"}}, summary.Headings)
+			require.Contains(t, summary.Paragraphs, "A deployment to Grafana Cloud via the plugins CD Argo Workflow has successfully been triggered.")
+			details, ok := summary.List("🐙 Grafana Cloud deployment via Argo Workflows")
+			require.True(t, ok, "summary should list the deployment details")
+			require.Equal(t, []string{"Plugin Version: 1.0.0", "Environment(s): " + *tc.inputs.Environment}, details.Items)
 			require.Contains(t, r.Summary[0], "**👉 You can follow the deployment [here](https://mock-argo-workflows.example.com/workflows/grafana-plugins-cd/mock-workflow-id)**")
 		})
 	}
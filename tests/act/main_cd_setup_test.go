@@ -12,6 +12,7 @@ import (
 )
 
 func TestCD_Setup(t *testing.T) {
+	// The plugin version suffix is the commit SHA: scrub the expected one by name, so a wrong SHA doesn't match the snapshots
 	gitSha, err := getGitCommitSHA()
 	require.NoError(t, err)
 
@@ -31,7 +32,7 @@ func TestCD_Setup(t *testing.T) {
 		name              string
 		inputs            cd.WorkflowInputs
 		workflowOptions   []cd.WorkflowOption
-		expOutputs        map[string]string
+		snapshotOutputs   []string
 		triggerEvent      *act.Event
 		expFailureMessage string
 	}
@@ -53,13 +54,8 @@ func TestCD_Setup(t *testing.T) {
 						},
 						Environment: workflow.Input("dev"),
 					},
-					expOutputs: map[string]string{
-						"environments":          `["dev"]`,
-						"publish-docs":          "false",
-						"plugin-version-suffix": "",
-						// Should have only "any" platform because it has no backend
-						"platforms": `["any"]`,
-					},
+					// platforms should have only "any" because it has no backend
+					snapshotOutputs: []string{"environments", "publish-docs", "plugin-version-suffix", "platforms"},
 				},
 				{
 					name: "simple-backend normal dev deployment",
@@ -69,13 +65,8 @@ func TestCD_Setup(t *testing.T) {
 						},
 						Environment: workflow.Input("dev"),
 					},
-					expOutputs: map[string]string{
-						"environments":          `["dev"]`,
-						"publish-docs":          "false",
-						"plugin-version-suffix": "",
-						// Should have all platforms because it has a backend
-						"platforms": `["linux","darwin","windows","any"]`,
-					},
+					// platforms should have all the platforms because it has a backend
+					snapshotOutputs: []string{"environments", "publish-docs", "plugin-version-suffix", "platforms"},
 				},
 			},
 		},
@@ -87,64 +78,50 @@ func TestCD_Setup(t *testing.T) {
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("ops"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["ops"]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "staging is an alias for ops",
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("staging"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["ops"]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "prod-canary deploys to prod-canary only",
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("prod-canary"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["prod-canary"]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "prod deploys to all environments",
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("prod"),
 					},
-					expOutputs: map[string]string{
-						// (prod-canary is implicitly included in prod)
-						"environments": `["dev","ops","prod"]`,
-					},
+					// (prod-canary is implicitly included in prod)
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "can target multiple environments",
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("dev,ops"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["dev","ops"]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "none does not deploy anything",
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("none"),
 					},
-					expOutputs: map[string]string{
-						"environments": `[]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "unsupported environments are filtered out",
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("dev,unsupported"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["dev"]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 				{
 					name: "no valid environments return an error",
@@ -174,9 +151,7 @@ func TestCD_Setup(t *testing.T) {
 						Environment:           workflow.Input("prod"),
 						ReleaseReferenceRegex: workflow.Input("release/.*"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["dev","ops","prod"]`,
-					},
+					snapshotOutputs: []string{"environments"},
 				},
 			},
 		},
@@ -189,9 +164,7 @@ func TestCD_Setup(t *testing.T) {
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("dev"),
 					},
-					expOutputs: map[string]string{
-						"plugin-version-suffix": gitSha,
-					},
+					snapshotOutputs: []string{"plugin-version-suffix"},
 				},
 				{
 					name:         "plugin version suffix is set if deploying to prod-canary from a release reference",
@@ -201,10 +174,7 @@ func TestCD_Setup(t *testing.T) {
 						// (default value, but let's be explicit)
 						ReleaseReferenceRegex: workflow.Input("main"),
 					},
-					expOutputs: map[string]string{
-						"environments":          `["prod-canary"]`,
-						"plugin-version-suffix": gitSha,
-					},
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 			},
 		}, {
@@ -212,13 +182,10 @@ func TestCD_Setup(t *testing.T) {
 			name: "auto-cd-example:push",
 			testCases: []testCase{
 				{
-					name:         "has plugin version suffix when deploying to dev",
-					triggerEvent: newPointer(act.NewPushEventPayload("main")),
-					inputs:       autoCDExamplePushInputs,
-					expOutputs: map[string]string{
-						"environments":          `["dev"]`,
-						"plugin-version-suffix": gitSha,
-					},
+					name:            "has plugin version suffix when deploying to dev",
+					triggerEvent:    newPointer(act.NewPushEventPayload("main")),
+					inputs:          autoCDExamplePushInputs,
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 			},
 		}, {
@@ -226,13 +193,10 @@ func TestCD_Setup(t *testing.T) {
 			name: "auto-cd-example:pull_request",
 			testCases: []testCase{
 				{
-					name:         "does not deploy pull requests automatically but adds plugin version suffix to ci build",
-					triggerEvent: newPointer(act.NewPullRequestEventPayload("feature-branch")),
-					inputs:       autoCDExamplePushInputs,
-					expOutputs: map[string]string{
-						"environments":          `[]`,
-						"plugin-version-suffix": gitSha,
-					},
+					name:            "does not deploy pull requests automatically but adds plugin version suffix to ci build",
+					triggerEvent:    newPointer(act.NewPullRequestEventPayload("feature-branch")),
+					inputs:          autoCDExamplePushInputs,
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 			},
 		}, {
@@ -247,11 +211,8 @@ func TestCD_Setup(t *testing.T) {
 							"environment": "prod",
 						}),
 					),
-					inputs: autoCDExamplePublishInputs,
-					expOutputs: map[string]string{
-						"environments":          `["dev","ops","prod"]`,
-						"plugin-version-suffix": "",
-					},
+					inputs:          autoCDExamplePublishInputs,
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 				{
 					name: "does not have plugin version suffix when deploying to dev",
@@ -261,11 +222,8 @@ func TestCD_Setup(t *testing.T) {
 							"environment": "dev",
 						}),
 					),
-					inputs: autoCDExamplePublishInputs,
-					expOutputs: map[string]string{
-						"environments":          `["dev"]`,
-						"plugin-version-suffix": "",
-					},
+					inputs:          autoCDExamplePublishInputs,
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 				{
 					name: "has plugin version suffix when deploying non-main to dev",
@@ -275,11 +233,8 @@ func TestCD_Setup(t *testing.T) {
 							"environment": "dev",
 						}),
 					),
-					inputs: autoCDExamplePublishInputs,
-					expOutputs: map[string]string{
-						"environments":          `["dev"]`,
-						"plugin-version-suffix": gitSha,
-					},
+					inputs:          autoCDExamplePublishInputs,
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 				{
 					name: "can deploy branches that match release reference regex to prod if there is no PR",
@@ -299,10 +254,7 @@ func TestCD_Setup(t *testing.T) {
 							}),
 						),
 					},
-					expOutputs: map[string]string{
-						"environments":          `["dev","ops","prod"]`,
-						"plugin-version-suffix": "",
-					},
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 				{
 					name: "cannot deploy PRs to prod",
@@ -353,10 +305,7 @@ func TestCD_Setup(t *testing.T) {
 							}),
 						),
 					},
-					expOutputs: map[string]string{
-						"environments":          `["dev","ops","prod"]`,
-						"plugin-version-suffix": "",
-					},
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 				{
 					name: "can force deploy a release branch to prod if there's an open PR and allow-publishing-prs-to-prod is true",
@@ -377,10 +326,7 @@ func TestCD_Setup(t *testing.T) {
 							}),
 						),
 					},
-					expOutputs: map[string]string{
-						"environments":          `["dev","ops","prod"]`,
-						"plugin-version-suffix": "",
-					},
+					snapshotOutputs: []string{"environments", "plugin-version-suffix"},
 				},
 			},
 		},
@@ -393,9 +339,7 @@ func TestCD_Setup(t *testing.T) {
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("dev"),
 					},
-					expOutputs: map[string]string{
-						"is-release-reference": "true",
-					},
+					snapshotOutputs: []string{"is-release-reference"},
 				},
 				{
 					name:         "false when branch does not match release-reference-regex",
@@ -403,9 +347,7 @@ func TestCD_Setup(t *testing.T) {
 					inputs: cd.WorkflowInputs{
 						Environment: workflow.Input("dev"),
 					},
-					expOutputs: map[string]string{
-						"is-release-reference": "false",
-					},
+					snapshotOutputs: []string{"is-release-reference"},
 				},
 				{
 					name:         "true when branch matches custom release-reference-regex",
@@ -414,9 +356,7 @@ func TestCD_Setup(t *testing.T) {
 						Environment:           workflow.Input("dev"),
 						ReleaseReferenceRegex: workflow.Input(`release/.*`),
 					},
-					expOutputs: map[string]string{
-						"is-release-reference": "true",
-					},
+					snapshotOutputs: []string{"is-release-reference"},
 				},
 			},
 		},
@@ -431,10 +371,8 @@ func TestCD_Setup(t *testing.T) {
 							"environment": "prod",
 						}),
 					),
-					inputs: autoCDExamplePublishInputs,
-					expOutputs: map[string]string{
-						"publish-docs": "true",
-					},
+					inputs:          autoCDExamplePublishInputs,
+					snapshotOutputs: []string{"publish-docs"},
 				},
 				{
 					name: "not published when not targeting prod",
@@ -444,10 +382,8 @@ func TestCD_Setup(t *testing.T) {
 							"environment": "dev",
 						}),
 					),
-					inputs: autoCDExamplePublishInputs,
-					expOutputs: map[string]string{
-						"publish-docs": "false",
-					},
+					inputs:          autoCDExamplePublishInputs,
+					snapshotOutputs: []string{"publish-docs"},
 				},
 				{
 					name:         "not published if disabled via input and targeting prod",
@@ -458,10 +394,7 @@ func TestCD_Setup(t *testing.T) {
 						Branch:      workflow.Input("main"),
 						Environment: workflow.Input("prod"),
 					},
-					expOutputs: map[string]string{
-						"environments": `["dev","ops","prod"]`,
-						"publish-docs": "false",
-					},
+					snapshotOutputs: []string{"environments", "publish-docs"},
 				},
 				{
 					name:         "docs-only does not publish the plugin",
@@ -471,10 +404,7 @@ func TestCD_Setup(t *testing.T) {
 						Branch:      workflow.Input("main"),
 						Environment: workflow.Input("prod"),
 					},
-					expOutputs: map[string]string{
-						"environments": `[]`,
-						"publish-docs": "true",
-					},
+					snapshotOutputs: []string{"environments", "publish-docs"},
 				},
 				{
 					name:         "docs-only fails if not targeting prod",
@@ -562,8 +492,8 @@ func TestCD_Setup(t *testing.T) {
 					require.NoError(t, err)
 
 					// Ensure the test case makes sense
-					if tc.expFailureMessage != "" && len(tc.expOutputs) > 0 {
-						require.FailNow(t, "expFailureMessage and expOutputs cannot be set at the same time")
+					if tc.expFailureMessage != "" && len(tc.snapshotOutputs) > 0 {
+						require.FailNow(t, "expFailureMessage and snapshotOutputs cannot be set at the same time")
 					}
 
 					// Assert
@@ -574,11 +504,12 @@ func TestCD_Setup(t *testing.T) {
 						require.Contains(t, r.Annotations[0].Message, tc.expFailureMessage, "annotation message should contain failure message")
 					} else {
 						require.True(t, r.Success, "workflow should succeed")
-						for k, exp := range tc.expOutputs {
-							o, ok := r.Outputs.Get("setup", "vars", k)
-							require.True(t, ok)
-							require.Equalf(t, exp, o, "output %q should be %q", k, exp)
-						}
+						act.AssertSnapshot(
+							t, r, act.SnapshotPath(t),
+							act.WithSnapshotJobs("setup"),
+							act.WithSnapshotOutputs(tc.snapshotOutputs...),
+							act.WithScrubbers(act.ValueScrubber("HEAD_SHA", gitSha)),
+						)
 					}
 				})
 			}
@@ -217,10 +217,12 @@ func TestCD(t *testing.T) {
 			require.Equal(t, 1, publishCalls, "GCOM API POST /plugins should be called exactly once")
 
 			// Assert summary content
-			require.Len(t, r.Summary, 1, "should have exactly one summary")
-			require.Contains(t, r.Summary[0], "## 📦 Published to Catalog (dev)")
-			require.Contains(t, r.Summary[0], "- **Plugin ID**: `"+tc.pluginSlug+"`")
-			require.Contains(t, r.Summary[0], "- **Version**: `"+pluginVersion+"`")
+			require.Len(t, r.StepSummaries, 1, "should have exactly one summary")
+			summary := r.StepSummaries[0]
+			require.Equal(t, []act.Heading{{Level: 2, Text: "📦 Published to Catalog (dev)"}}, summary.Headings)
+			details, ok := summary.List("📦 Published to Catalog (dev)")
+			require.True(t, ok, "summary should list the published plugin details")
+			require.Equal(t, []string{"Plugin ID: " + tc.pluginSlug, "Version: " + pluginVersion}, details.Items)
 
 			// Check GCS release upload
 			expGCSFiles := []string{
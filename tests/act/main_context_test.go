@@ -299,12 +299,7 @@ func TestContext(t *testing.T) {
 				)
 				require.NoError(t, err)
 
-				releaseEvent := act.NewEventPayload(act.EventKindRelease, map[string]any{
-					"action": "published",
-					"release": map[string]any{
-						"tag_name": "v1.0.0",
-					},
-				}, act.WithEventActor(tc.actor))
+				releaseEvent := act.NewReleaseEventPayload("v1.0.0", act.WithEventActor(tc.actor))
 
 				r, err := runner.Run(wf, releaseEvent)
 				require.NoError(t, err)
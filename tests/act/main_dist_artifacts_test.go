@@ -97,7 +97,7 @@ func TestDistArtifactsUnavailable(t *testing.T) {
 			require.NoError(t, err)
 
 			require.False(t, r.Success, "workflow should fail when dist-artifacts are unavailable")
-			require.Contains(t, r.Annotations, act.Annotation{
+			require.Contains(t, r.Annotations.WithoutLocation(), act.Annotation{
 				Level:   act.AnnotationLevelError,
 				Message: tc.expAnnotationMessage,
 			})
@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"path/filepath"
 	"slices"
 	"testing"
 
 	"github.com/grafana/plugin-ci-workflows/tests/act/internal/act"
+	"github.com/grafana/plugin-ci-workflows/tests/act/internal/golden"
 	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow"
 	"github.com/grafana/plugin-ci-workflows/tests/act/internal/workflow/ci"
 	"github.com/stretchr/testify/require"
@@ -83,39 +83,19 @@ func TestGCS(t *testing.T) {
 					require.NoError(t, err)
 					require.True(t, r.Success, "workflow should succeed")
 
-					// Assert files uploaded to GCS (commit hash)
+					// Assert files uploaded to GCS: zips (with .md5 and .sha1) in the commit hash folder,
+					// and also in the "latest" folder if the event is a push to main, rather than a PR
+					golden.AssertTree(
+						t,
+						runner.GCS.Fs,
+						filepath.Join("tests", "act", "testdata", "gcs", tc.folder+"-"+string(event.Kind)+".tree"),
+						golden.WithPlaceholders(golden.VersionPlaceholder, golden.SHAPlaceholder),
+					)
 					anyZipFn := anyZipFileName(tc.id, tc.version)
-					commitBasePath := filepath.Join("integration-artifacts", tc.id, tc.version, "main", commitHash)
-					latestBasePath := filepath.Join("integration-artifacts", tc.id, tc.version, "main", "latest")
-
-					// Expect commit hash any zip
-					expFiles := []string{filepath.Join(commitBasePath, anyZipFn)}
-					if event.Kind == act.EventKindPush {
-						// Also expect zips in the "latest" folder if the event is a push to main, rather than a PR
-						expFiles = append(expFiles, filepath.Join(latestBasePath, anyZipFn))
-					}
-					if tc.hasBackend {
-						// Expect backend zips
-						for _, osArch := range osArchCombos {
-							// Expect commit hash os/arch zip
-							backendZipFn := osArchZipFileName(tc.id, tc.version, osArch)
-							expFiles = append(expFiles, filepath.Join(commitBasePath, backendZipFn))
-
-							if event.Kind == act.EventKindPush {
-								// Also latest os/arch zip
-								expFiles = append(expFiles, filepath.Join(latestBasePath, backendZipFn))
-							}
-						}
-					}
-
-					// For each zip file, expect the corresponding .md5 and .sha1 files
-					for _, fn := range expFiles {
-						expFiles = append(expFiles, fn+".md5", fn+".sha1")
-					}
 
-					// Check files in mocked GCS
-					err = checkFilesExist(runner.GCS.Fs, expFiles, checkFilesExistOptions{strict: true})
-					require.NoErrorf(t, err, "wrong files uploaded to GCS (commit hash)")
+					// Assert GCS paths exported via GITHUB_ENV for the upload steps
+					require.Equal(t, filepath.Join("integration-artifacts", tc.id, tc.version, "main", commitHash), r.Env["upload-to-gcs"]["gcs_artifacts_path_commit"])
+					require.Equal(t, filepath.Join("integration-artifacts", tc.id, tc.version, "main", "latest"), r.Env["upload-to-gcs"]["gcs_artifacts_path_latest"])
 
 					// Assert job outputs (GCS URLs used later for publishing)
 					// Check universal ZIP output
@@ -161,11 +141,8 @@ func TestGCS(t *testing.T) {
 						if !exp.shouldBePresentInPR && event.Kind == act.EventKindPullRequest {
 							continue
 						}
-						jsonOutput, ok := r.Outputs.Get("upload-to-gcs", "outputs", exp.outputName)
-						require.Truef(t, ok, "output %q should be present", exp.outputName)
-						output := make([]string, 0, len(expLatestZIPURLs))
-						err = json.Unmarshal([]byte(jsonOutput), &output)
-						require.NoError(t, err)
+						output, err := act.OutputJSON[[]string](r, "upload-to-gcs", "outputs", exp.outputName)
+						require.NoErrorf(t, err, "output %q should be present", exp.outputName)
 						// Compare sorted slices
 						slices.Sort(output)
 						require.Equal(t, exp.expected, output)
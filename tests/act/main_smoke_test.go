@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"path/filepath"
 	"testing"
 
@@ -82,11 +81,8 @@ func TestSmoke(t *testing.T) {
 			require.True(t, r.Success, "workflow should succeed")
 
 			// Assert outputs
-			var pluginOutput testAndBuildOutput
-			rawOutput, ok := r.Outputs.Get("test-and-build", "outputs", "plugin")
-			require.True(t, ok, "plugin output should be present")
-			err = json.Unmarshal([]byte(rawOutput), &pluginOutput)
-			require.NoError(t, err, "unmarshal plugin output JSON")
+			pluginOutput, err := act.OutputJSON[testAndBuildOutput](r, "test-and-build", "outputs", "plugin")
+			require.NoError(t, err, "plugin output should be present and valid JSON")
 			require.Equal(t, tc.exp, pluginOutput)
 
 			// Sanity check the artifacts content (plugin ZIP files)
@@ -112,6 +108,12 @@ func TestSmoke(t *testing.T) {
 			}
 			require.NoError(t, checkFilesExist(distArtifacts.Fs, expFns, checkFilesExistOptions{strict: true}))
 
+			distArtifact, err := runner.ArtifactsStorage.Get(runID, tc.folder+"-dist-artifacts")
+			require.NoError(t, err)
+			require.ElementsMatch(t, expFns, distArtifact.FilePaths())
+			require.NoError(t, distArtifact.RetentionErr)
+			require.Equal(t, 10, distArtifact.RetentionDays, "dist artifacts should use the default retention of the ci workflow")
+
 			// Sanity check the content of the "any" zip file
 			zfs, err := distArtifacts.OpenZIP(anyZipFn)
 			require.NoError(t, err)
@@ -183,7 +183,7 @@ analyzers:
 			if tc.expPluginValidatorVersion != "" {
 				logFmtMsg, err := logfmt.MarshalKeyvals("msg", "Running plugin-validator", "version", tc.expPluginValidatorVersion)
 				require.NoError(t, err)
-				require.Contains(t, r.Annotations, act.Annotation{
+				require.Contains(t, r.Annotations.WithoutLocation(), act.Annotation{
 					Level:   act.AnnotationLevelDebug,
 					Message: string(logFmtMsg),
 				})
@@ -193,7 +193,7 @@ analyzers:
 			if tc.expPluginValidatorConfigSource != "" {
 				logFmtMsg, err := logfmt.MarshalKeyvals("msg", "plugin-validator configuration", "source", tc.expPluginValidatorConfigSource)
 				require.NoError(t, err)
-				require.Contains(t, r.Annotations, act.Annotation{
+				require.Contains(t, r.Annotations.WithoutLocation(), act.Annotation{
 					Level:   act.AnnotationLevelDebug,
 					Message: string(logFmtMsg),
 				})
@@ -201,7 +201,7 @@ analyzers:
 
 			// Check expected annotation entries and exact count
 			if tc.expAnnotations != nil {
-				require.Subset(t, r.Annotations, tc.expAnnotations)
+				require.Subset(t, r.Annotations.WithoutLocation(), tc.expAnnotations)
 				var validatorAnnotationCount int
 				for _, s := range r.Annotations {
 					if strings.HasPrefix(s.Title, "plugin-validator:") {